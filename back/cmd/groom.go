@@ -22,15 +22,48 @@ func init() {
 
 var groomCmd = &cobra.Command{
 	Use:   "groom",
-	Short: "Load and groom a wrap file",
+	Short: "Load and groom wrap, menu or fragment files. Composite wraps are resolved against the other provided files",
 	Run: func(cmd *cobra.Command, args []string) {
+		// Load all files first, as composite wraps are resolved against the other provided files
+		docs := make([]*wrap.Document, 0, len(args))
 		for _, fileName := range args {
-			fmt.Printf("\n---------------------------------------------- Processing file %s\n", fileName)
-			w, err := wrap.Load(fileName)
+			doc, err := wrap.LoadDocument(fileName)
 			if err != nil {
 				log.Fatal(err)
 			}
-			if w != nil {
+			docs = append(docs, doc)
+		}
+		lookup := func(kind string, name string) *wrap.Document {
+			for _, doc := range docs {
+				switch {
+				case doc == nil:
+				case kind == "fragment" && doc.Fragment != nil && doc.Fragment.Name == name:
+					return doc
+				case kind == "wrap" && doc.Wrap != nil && doc.Wrap.Name == name:
+					return doc
+				case kind == "wrap" && doc.Composite != nil && doc.Composite.Name == name:
+					return doc
+				}
+			}
+			return nil
+		}
+		for idx, fileName := range args {
+			fmt.Printf("\n---------------------------------------------- Processing file %s\n", fileName)
+			doc := docs[idx]
+			if doc != nil {
+				var w interface{} = doc.Wrap
+				switch {
+				case doc.Menu != nil:
+					w = doc.Menu
+				case doc.Fragment != nil:
+					w = doc.Fragment
+				case doc.Composite != nil:
+					resolved, _, err := doc.Resolve(lookup)
+					if err != nil {
+						log.Fatal(err)
+					}
+					w = resolved
+				}
 				if groomParams.json {
 					jsonData, err := json.MarshalIndent(w, "", "  ")
 					if err != nil {
@@ -47,7 +80,7 @@ var groomCmd = &cobra.Command{
 					fmt.Println(string(yamlData))
 				}
 			} else {
-				fmt.Printf("Not a wrap file")
+				fmt.Printf("Not a krapper file")
 			}
 		}
 	},
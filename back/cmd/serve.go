@@ -3,24 +3,48 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"krapper/internal/audit"
+	"krapper/internal/auth"
+	"krapper/internal/builtin"
 	"krapper/internal/global"
 	"krapper/internal/httpsrv"
 	"krapper/internal/k8s"
 	"krapper/internal/misc"
+	"krapper/internal/wrap"
 	"krapper/internal/wrapstore"
 	"log/slog"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var serveParams struct {
-	logConfig   misc.LogConfig
-	httpConfig  httpsrv.Config
-	wrapsFolder string
+	logConfig             misc.LogConfig
+	httpConfig            httpsrv.Config
+	wrapsFolders          []string
+	builtinWraps          bool
+	clusterWraps          bool
+	clusterWrapsNamespace string
+	clusterWrapsInterval  time.Duration
+	fallbackLocale        string
+	auditFile             string
+	auditMaxSize          int
+	auditMaxBackups       int
+	auditLog              bool
+	auditEvents           bool
+	auditRecent           int
+	oidcConfig            auth.OidcConfig
 }
 
 func init() {
@@ -34,8 +58,29 @@ func init() {
 	serveCmd.PersistentFlags().StringVarP(&serveParams.httpConfig.CertDir, "certDir", "", "", "Certificate Directory")
 	serveCmd.PersistentFlags().StringVar(&serveParams.httpConfig.CertName, "certName", "tls.crt", "Certificate Directory")
 	serveCmd.PersistentFlags().StringVar(&serveParams.httpConfig.KeyName, "keyName", "tls.key", "Certificate Directory")
-	serveCmd.PersistentFlags().StringVar(&serveParams.wrapsFolder, "wrapsFolder", "", "Path to wraps directory")
-	_ = serveCmd.MarkPersistentFlagRequired("wrapsFolder")
+	serveCmd.PersistentFlags().StringArrayVar(&serveParams.wrapsFolders, "wrapsFolder", nil, "Path to wraps directory. May be repeated. On wrap name collision, last folder wins")
+	serveCmd.PersistentFlags().BoolVar(&serveParams.builtinWraps, "builtinWraps", true, "Load wraps compiled into the binary. Lowest precedence on name collision")
+	serveCmd.PersistentFlags().BoolVar(&serveParams.clusterWraps, "clusterWraps", false, "Load wraps from ConfigMaps labeled '"+wrapstore.ClusterWrapLabel+"=true'. Take precedence over builtin ones, but not over folders")
+	serveCmd.PersistentFlags().StringVar(&serveParams.clusterWrapsNamespace, "clusterWrapsNamespace", "", "Namespace of wraps ConfigMaps. Required with --clusterWraps, as wraps run with krapper credentials")
+	serveCmd.PersistentFlags().DurationVar(&serveParams.clusterWrapsInterval, "clusterWrapsInterval", 30*time.Second, "Polling interval for wraps ConfigMaps")
+	serveCmd.PersistentFlags().StringVar(&serveParams.fallbackLocale, "fallbackLocale", "en", "Locale of the wrap texts when none of the Accept-Language ones is provided")
+	serveCmd.PersistentFlags().StringVar(&serveParams.auditFile, "auditFile", "", "Write audit records as JSON lines to this file. Disabled if empty")
+	serveCmd.PersistentFlags().IntVar(&serveParams.auditMaxSize, "auditMaxSize", 100, "Size (in MB) of the audit file triggering a rotation")
+	serveCmd.PersistentFlags().IntVar(&serveParams.auditMaxBackups, "auditMaxBackups", 5, "Number of rotated audit files to keep")
+	serveCmd.PersistentFlags().BoolVar(&serveParams.auditLog, "auditLog", false, "Write audit records to the server log")
+	serveCmd.PersistentFlags().BoolVar(&serveParams.auditEvents, "auditEvents", false, "Write audit records as Kubernetes Events on the target objects")
+	serveCmd.PersistentFlags().IntVar(&serveParams.auditRecent, "auditRecent", 1000, "Number of recent audit records kept in memory, for the query endpoint")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.Issuer, "oidcIssuer", "", "OIDC issuer URL. If empty, authentication is disabled and all requests are anonymous")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.ClientId, "oidcClientId", "krapper", "OIDC client id")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.ClientSecret, "oidcClientSecret", "", "OIDC client secret. Default to $KRAPPER_OIDC_CLIENT_SECRET. May be empty for a public client (PKCE only)")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.RedirectUrl, "oidcRedirectUrl", "", "OIDC redirect URL. Must point to '.../api/v1/auth/callback'")
+	serveCmd.PersistentFlags().StringSliceVar(&serveParams.oidcConfig.Scopes, "oidcScopes", []string{"profile", "email", "groups"}, "OIDC scopes, in addition to 'openid'")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.LoginClaim, "oidcLoginClaim", "preferred_username", "id_token claim providing the user login. Fallback to 'sub'")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.GroupsClaim, "oidcGroupsClaim", "groups", "id_token claim providing the user groups")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.GroupsPrefix, "oidcGroupsPrefix", "", "Prefix added to the groups of the id_token")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.PostLogoutUrl, "oidcPostLogoutUrl", "", "URL the provider redirect to after logout")
+	serveCmd.PersistentFlags().StringVar(&serveParams.oidcConfig.SessionKey, "sessionKey", "", "Secret used to encrypt session cookies. Default to $KRAPPER_SESSION_KEY. Random if empty")
+	serveCmd.PersistentFlags().DurationVar(&serveParams.oidcConfig.SessionTtl, "sessionTtl", 8*time.Hour, "Session lifetime")
 }
 
 var serveCmd = &cobra.Command{
@@ -49,15 +94,9 @@ var serveCmd = &cobra.Command{
 		}
 		logger.Info("Starting krapper server", slog.String("logLevel", serveParams.logConfig.Level), slog.String("version", global.Version), slog.String("build", global.BuildTs))
 
-		// Create and start HTTP server
-		store, err := wrapstore.New(serveParams.wrapsFolder, logger)
-		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "Unable to load wraps from '%s': %v\n", serveParams.wrapsFolder, err)
-			os.Exit(2)
-		}
-
-		// Inject logger into context
+		// Inject logger into context, and as the default one (for packages without a context, i.e. wrap)
 		ctx := logr.NewContextWithSlogLogger(context.Background(), logger)
+		slog.SetDefault(logger)
 
 		// Initialize K8s client
 		k8sClient, err := k8s.NewClient(logger)
@@ -65,35 +104,188 @@ var serveCmd = &cobra.Command{
 			logger.Warn("Failed to initialize K8s client. K8s features will be disabled.", "error", err)
 		}
 
+		wrap.FallbackLocale = serveParams.fallbackLocale
+
+		// Setup wrap sources, by increasing precedence
+		sources := make([]wrapstore.Source, 0, len(serveParams.wrapsFolders)+2)
+		if serveParams.builtinWraps {
+			sources = append(sources, wrapstore.NewEmbeddedSource(builtin.Wraps, logger))
+		}
+		if serveParams.clusterWraps {
+			if k8sClient == nil {
+				logger.Warn("No K8s client. Wraps will not be loaded from cluster")
+			} else {
+				source, err := wrapstore.NewClusterSource(ctx, k8sClient, serveParams.clusterWrapsNamespace, serveParams.clusterWrapsInterval, logger)
+				if err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "Unable to load wraps from cluster: %v\n", err)
+					os.Exit(2)
+				}
+				sources = append(sources, source)
+			}
+		}
+		for _, folder := range serveParams.wrapsFolders {
+			source, err := wrapstore.NewFolderSource(folder, logger)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Unable to load wraps from '%s': %v\n", folder, err)
+				os.Exit(2)
+			}
+			sources = append(sources, source)
+		}
+		store, err := wrapstore.New(logger, sources...)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Unable to load wraps: %v\n", err)
+			os.Exit(2)
+		}
+
+		// Setup audit sinks
+		auditSinks := make([]audit.Sink, 0, 3)
+		if serveParams.auditFile != "" {
+			sink, err := audit.NewFileSink(serveParams.auditFile, int64(serveParams.auditMaxSize)*1024*1024, serveParams.auditMaxBackups)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Unable to setup audit file: %v\n", err)
+				os.Exit(2)
+			}
+			auditSinks = append(auditSinks, sink)
+		}
+		if serveParams.auditLog {
+			auditSinks = append(auditSinks, audit.NewLoggerSink(logger))
+		}
+		if serveParams.auditEvents {
+			if k8sClient == nil {
+				logger.Warn("No K8s client. Audit records will not be written as events")
+			} else {
+				auditSinks = append(auditSinks, audit.NewEventSink(k8sClient))
+			}
+		}
+		recorder := audit.NewRecorder(logger, serveParams.auditRecent, auditSinks...)
+
 		mux := http.NewServeMux()
 
+		var handler http.Handler = mux
+		// Secrets are preferably provided by the environment, so they don't show in the process list, nor as flag defaults in --help
+		if serveParams.oidcConfig.ClientSecret == "" {
+			serveParams.oidcConfig.ClientSecret = os.Getenv("KRAPPER_OIDC_CLIENT_SECRET")
+		}
+		if serveParams.oidcConfig.SessionKey == "" {
+			serveParams.oidcConfig.SessionKey = os.Getenv("KRAPPER_SESSION_KEY")
+		}
+		if serveParams.oidcConfig.Issuer != "" {
+			authenticator, err := auth.NewAuthenticator(ctx, &serveParams.oidcConfig, logger)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Unable to setup OIDC authentication: %v\n", err)
+				os.Exit(2)
+			}
+			authenticator.Register(mux)
+			handler = authenticator.Middleware(mux)
+		} else {
+			logger.Warn("No OIDC issuer provided. Authentication is disabled")
+		}
+
+		mux.HandleFunc("GET /api/v1/me", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(auth.FromContext(r.Context())); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+
 		mux.HandleFunc("GET /api/v1/wraps", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(store.GetCatalog()); err != nil {
+			w.Header().Set("Vary", "Accept-Language")
+			if err := json.NewEncoder(w).Encode(store.GetUserCatalog(requestLocales(r), auth.FromContext(r.Context()))); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
 		})
 
 		mux.HandleFunc("GET /api/v1/wraps/{name}", func(w http.ResponseWriter, r *http.Request) {
 			name := r.PathValue("name")
-			wrap := store.GetWrap(name)
-			if wrap == nil {
+			wr := store.GetWrap(name)
+			if wr == nil {
 				http.Error(w, "Wrap not found", http.StatusNotFound)
 				return
 			}
+			if !wr.Usable(auth.FromContext(r.Context())) {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Vary", "Accept-Language")
+			if err := json.NewEncoder(w).Encode(wr.Localize(requestLocales(r))); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+
+		mux.HandleFunc("GET /api/v1/wraps/{name}/defaults", func(w http.ResponseWriter, r *http.Request) {
+			wr := store.GetWrap(r.PathValue("name"))
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			// Only used by the edit form
+			if operations := wr.UserOperations(auth.FromContext(r.Context())); !operations.Create && !operations.Update {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			ns := wr.TargetNamespace(r.URL.Query().Get("namespace"))
+			defaults, err := wr.Defaults(auth.FromContext(r.Context()), ns, time.Now())
+			if err != nil {
+				logger.Error("Failed to resolve defaults", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(defaults); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+
+		mux.HandleFunc("GET /api/v1/wraps/{name}/references/{field}", func(w http.ResponseWriter, r *http.Request) {
+			wr := store.GetWrap(r.PathValue("name"))
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			// Only used by the edit form
+			if operations := wr.UserOperations(auth.FromContext(r.Context())); !operations.Create && !operations.Update {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			reference := wr.FindReference(r.PathValue("field"))
+			if reference == nil {
+				http.Error(w, "Reference field not found", http.StatusNotFound)
+				return
+			}
+			if k8sClient == nil {
+				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
+				return
+			}
+			lookup := viewableWraps(store, auth.FromContext(r.Context()))
+			if reference.Wrap != "" && lookup(reference.Wrap) == nil {
+				http.Error(w, "Referenced wrap not allowed", http.StatusForbidden)
+				return
+			}
+			options, err := reference.ListOptions(r.Context(), k8sClient, lookup, wr.TargetNamespace(r.URL.Query().Get("namespace")))
+			if err != nil {
+				logger.Error("Failed to list reference options", "error", err, "wrap", wr.Name, "field", r.PathValue("field"))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(wrap); err != nil {
+			if err := json.NewEncoder(w).Encode(options); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
 		})
 
 		mux.HandleFunc("GET /api/v1/resources/{wrapName}", func(w http.ResponseWriter, r *http.Request) {
 			name := r.PathValue("wrapName")
-			wrap := store.GetWrap(name)
-			if wrap == nil {
+			wr := store.GetWrap(name)
+			if wr == nil {
 				http.Error(w, "Wrap not found", http.StatusNotFound)
 				return
 			}
+			if !wr.UserOperations(auth.FromContext(r.Context())).View {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
 
 			if k8sClient == nil {
 				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
@@ -101,36 +293,485 @@ var serveCmd = &cobra.Command{
 			}
 
 			// Determine namespace
-			ns := wrap.Source.Namespace
-			if wrap.Source.ClusterScoped {
+			ns := wr.Source.Namespace
+			if wr.Source.ClusterScoped {
 				ns = "" // Ignore namespace for cluster scoped resources
 			}
 
 			list, err := k8sClient.ListResources(
 				r.Context(),
-				wrap.Source.ApiVersion,
-				wrap.Source.Kind,
+				wr.Source.ApiVersion,
+				wr.Source.Kind,
 				ns,
-				wrap.Source.Selector,
+				wr.Source.Selector,
 			)
 			if err != nil {
-				logger.Error("Failed to list resources", "error", err, "wrap", wrap.Name)
+				logger.Error("Failed to list resources", "error", err, "wrap", wr.Name)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			// Clean up resources
+			// Clean up resources. Optionally filter by health (i.e. '?health=degraded,progressing')
+			var healths []string
+			if h := r.URL.Query().Get("health"); h != "" {
+				healths = strings.Split(h, ",")
+			}
+			items := make([]unstructured.Unstructured, 0, len(list.Items))
 			for i := range list.Items {
+				if !wr.MatchHealth(&list.Items[i], healths) {
+					continue
+				}
 				list.Items[i].SetManagedFields(nil)
+				wr.Decorate(&list.Items[i])
+				items = append(items, list.Items[i])
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(items); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+
+		mux.HandleFunc("GET /api/v1/resources/{wrapName}/{name}", func(w http.ResponseWriter, r *http.Request) {
+			wr := store.GetWrap(r.PathValue("wrapName"))
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			if !wr.UserOperations(auth.FromContext(r.Context())).View {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			if k8sClient == nil {
+				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
+				return
+			}
+			ns := wr.TargetNamespace(r.URL.Query().Get("namespace"))
+			obj, err := wr.GetObject(r.Context(), k8sClient, ns, r.PathValue("name"))
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					http.Error(w, "Resource not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("Failed to get resource", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			obj.SetManagedFields(nil)
+			wr.Decorate(obj)
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(obj); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+
+		mux.HandleFunc("PUT /api/v1/resources/{wrapName}", func(w http.ResponseWriter, r *http.Request) {
+			name := r.PathValue("wrapName")
+			wr := store.GetWrap(name)
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			wr = wr.Localize(requestLocales(r)) // For validation messages
+			operations := wr.UserOperations(auth.FromContext(r.Context()))
+			if !operations.Create && !operations.Update {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			if k8sClient == nil {
+				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
+				return
+			}
+
+			var submission struct {
+				Metadata map[string]interface{} `json:"metadata"`
+				Fields   map[string]interface{} `json:"fields"`
+			}
+			decoder := json.NewDecoder(r.Body)
+			decoder.UseNumber()
+			if err := decoder.Decode(&submission); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid payload: %v", err), http.StatusBadRequest)
+				return
+			}
+			if submission.Metadata == nil {
+				submission.Metadata = make(map[string]interface{})
+			}
+
+			// Determine namespace
+			requested, _ := submission.Metadata["namespace"].(string)
+			ns := wr.TargetNamespace(requested)
+			if wr.Source.ClusterScoped {
+				delete(submission.Metadata, "namespace")
+			} else {
+				if ns == "" {
+					http.Error(w, "Namespace is required", http.StatusBadRequest)
+					return
+				}
+				submission.Metadata["namespace"] = ns
+			}
+
+			// On update, the client provides the object name, so that the existing object is available to expressions
+			var current *unstructured.Unstructured
+			objName, _ := submission.Metadata["name"].(string)
+			if objName != "" {
+				var err error
+				current, err = wr.GetObject(r.Context(), k8sClient, ns, objName)
+				if err != nil {
+					if !apierrors.IsNotFound(err) {
+						logger.Error("Failed to get resource", "error", err, "wrap", wr.Name)
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+					current = nil
+				}
+			}
+			fields, inactive, err := wr.CheckValues(submission.Fields, current)
+			if err != nil {
+				httpValidationError(w, err)
+				return
+			}
+			err = wr.CheckReferences(r.Context(), fields, k8sClient, viewableWraps(store, auth.FromContext(r.Context())), ns)
+			if err != nil {
+				httpValidationError(w, err)
+				return
+			}
+			secrets, err := wr.PrepareSecrets(fields, ns)
+			if err != nil {
+				logger.Error("Failed to prepare secrets", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			set, err := wr.Render(&wrap.TemplateData{Fields: fields, Metadata: submission.Metadata})
+			if err != nil {
+				logger.Error("Failed to render resource", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			obj := set.Primary
+			if objName != "" && obj.GetName() != objName {
+				http.Error(w, fmt.Sprintf("Rendered resource must be named '%s'", objName), http.StatusBadRequest)
+				return
+			}
+			if obj.GetNamespace() != ns {
+				logger.Error("Rendered resource is not in target namespace", "wrap", wr.Name, "namespace", obj.GetNamespace(), "expected", ns)
+				http.Error(w, fmt.Sprintf("Rendered resource must be in namespace '%s'", ns), http.StatusInternalServerError)
+				return
 			}
+			// Ensure the resource will be listed
+			if len(wr.Source.Selector) > 0 {
+				labels := obj.GetLabels()
+				if labels == nil {
+					labels = make(map[string]string)
+				}
+				for k, v := range wr.Source.Selector {
+					labels[k] = v
+				}
+				obj.SetLabels(labels)
+			}
+
+			existing, err := wr.GetObject(r.Context(), k8sClient, ns, obj.GetName())
+			if err == nil && !operations.Update {
+				http.Error(w, "Update not allowed", http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				if !apierrors.IsNotFound(err) {
+					logger.Error("Failed to get resource", "error", err, "wrap", wr.Name)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if !operations.Create {
+					http.Error(w, "Creation not allowed", http.StatusForbidden)
+					return
+				}
+				existing = nil
+			}
+			kept, err := wr.KeepSecrets(obj, existing, fields, inactive)
+			if err != nil {
+				httpValidationError(w, err)
+				return
+			}
+
+			// Secrets are applied, and deleted, with the other objects of the set. The ones of not resubmitted values are left as is
+			set.Add(secrets...)
+			set.Keep(kept...)
+			operation := audit.OpUpdate
+			if existing == nil {
+				operation = audit.OpCreate
+			}
+			applied, err := set.ApplyObjects(r.Context(), k8sClient)
+			recorder.Record(r.Context(), newAuditRecord(r, wr, operation, auditRef(obj, existing, applied), existing, applied, err))
+			if err != nil {
+				// i.e. a field owned by another manager
+				if apierrors.IsConflict(err) {
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				}
+				logger.Error("Failed to apply resources", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err := wrap.DeleteObjects(r.Context(), k8sClient, set.Stale(existing), set.Owner); err != nil {
+				logger.Warn("Failed to delete objects no longer rendered", "error", err, "wrap", wr.Name)
+			}
+			applied.SetManagedFields(nil)
+			wr.Decorate(applied)
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(applied); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+
+		mux.HandleFunc("GET /api/v1/resources/{wrapName}/{name}/related", func(w http.ResponseWriter, r *http.Request) {
+			wr := store.GetWrap(r.PathValue("wrapName"))
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			if !wr.UserOperations(auth.FromContext(r.Context())).View {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			wr = wr.Localize(requestLocales(r))
+			if k8sClient == nil {
+				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
+				return
+			}
+			ns := wr.TargetNamespace(r.URL.Query().Get("namespace"))
+			obj, err := wr.GetObject(r.Context(), k8sClient, ns, r.PathValue("name"))
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					http.Error(w, "Resource not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("Failed to get resource", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Related objects of a wrap the user can't view are reported as from an unknown wrap
+			related := wr.ListRelated(r.Context(), k8sClient, viewableWraps(store, auth.FromContext(r.Context())), obj)
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(related); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+
+		mux.HandleFunc("GET /api/v1/resources/{wrapName}/{name}/events", func(w http.ResponseWriter, r *http.Request) {
+			wr := store.GetWrap(r.PathValue("wrapName"))
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			if !wr.UserOperations(auth.FromContext(r.Context())).View {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			if k8sClient == nil {
+				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
+				return
+			}
+			ns := wr.TargetNamespace(r.URL.Query().Get("namespace"))
+			obj, err := wr.GetObject(r.Context(), k8sClient, ns, r.PathValue("name"))
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					http.Error(w, "Resource not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("Failed to get resource", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// For a cluster scoped object, namespace is empty: Events are searched in all namespaces
+			events, resourceVersion, err := k8sClient.ListEvents(r.Context(), obj.GetNamespace(), string(obj.GetUID()))
+			if err != nil {
+				logger.Error("Failed to list events", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if r.URL.Query().Get("watch") != "true" {
+				w.Header().Set("Content-Type", "application/json")
+				if err := json.NewEncoder(w).Encode(events); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
+			// Live streaming, as Server-Sent Events: current events, then new or updated ones, from the list version
+			stream, err := k8sClient.WatchEvents(r.Context(), obj.GetNamespace(), string(obj.GetUID()), resourceVersion)
+			if err != nil {
+				logger.Error("Failed to watch events", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			rc := http.NewResponseController(w)
+			_ = rc.SetWriteDeadline(time.Time{})
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			send := func(event k8s.Event) bool {
+				data, _ := json.Marshal(event)
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+					return false
+				}
+				return rc.Flush() == nil
+			}
+			for _, event := range events {
+				if !send(event) {
+					return
+				}
+			}
+			_ = rc.Flush()
+			for event := range stream {
+				if !send(event) {
+					return
+				}
+			}
+		})
+
+		mux.HandleFunc("POST /api/v1/resources/{wrapName}/{name}/actions/{action}", func(w http.ResponseWriter, r *http.Request) {
+			wr := store.GetWrap(r.PathValue("wrapName"))
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			wr = wr.Localize(requestLocales(r)) // For validation messages
+			action := wr.FindAction(r.PathValue("action"))
+			if action == nil {
+				http.Error(w, "Action not found", http.StatusNotFound)
+				return
+			}
+			identity := auth.FromContext(r.Context())
+			if !wr.AllowsAction(action, identity) {
+				http.Error(w, "Action not allowed", http.StatusForbidden)
+				return
+			}
+			if k8sClient == nil {
+				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
+				return
+			}
+			var submission struct {
+				Fields map[string]interface{} `json:"fields"`
+			}
+			if r.ContentLength != 0 {
+				decoder := json.NewDecoder(r.Body)
+				decoder.UseNumber()
+				if err := decoder.Decode(&submission); err != nil && !errors.Is(err, io.EOF) {
+					http.Error(w, fmt.Sprintf("Invalid payload: %v", err), http.StatusBadRequest)
+					return
+				}
+			}
+			ns := wr.TargetNamespace(r.URL.Query().Get("namespace"))
+			obj, err := wr.GetObject(r.Context(), k8sClient, ns, r.PathValue("name"))
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					http.Error(w, "Resource not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("Failed to get resource", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			fields, err := action.CheckValues(submission.Fields, obj)
+			if err != nil {
+				httpValidationError(w, err)
+				return
+			}
+			patch, err := action.Render(&wrap.ActionData{
+				Resource: obj.Object,
+				Fields:   fields,
+				User:     identity,
+				Now:      time.Now().UTC().Format(time.RFC3339),
+			})
+			if err != nil {
+				logger.Error("Failed to render action patch", "error", err, "wrap", wr.Name, "action", action.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			patched, err := k8sClient.PatchResource(r.Context(), obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName(), patch)
+			record := newAuditRecord(r, wr, audit.OpAction, auditRef(obj, obj, patched), obj, patched, err)
+			record.Action = action.Name
+			recorder.Record(r.Context(), record)
+			if err != nil {
+				logger.Error("Failed to perform action", "error", err, "wrap", wr.Name, "action", action.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			logger.Info("Action performed", "wrap", wr.Name, "action", action.Name, "namespace", obj.GetNamespace(), "name", obj.GetName(), "user", identity.Login)
+			// The action right doesn't grant the view of the object
+			if !wr.UserOperations(identity).View {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			patched.SetManagedFields(nil)
+			wr.Decorate(patched)
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(patched); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
 
+		mux.HandleFunc("DELETE /api/v1/resources/{wrapName}/{name}", func(w http.ResponseWriter, r *http.Request) {
+			wr := store.GetWrap(r.PathValue("wrapName"))
+			if wr == nil {
+				http.Error(w, "Wrap not found", http.StatusNotFound)
+				return
+			}
+			if !wr.UserOperations(auth.FromContext(r.Context())).Delete {
+				http.Error(w, "Operation not allowed", http.StatusForbidden)
+				return
+			}
+			if k8sClient == nil {
+				http.Error(w, "K8s client not initialized", http.StatusServiceUnavailable)
+				return
+			}
+			ns := wr.TargetNamespace(r.URL.Query().Get("namespace"))
+			obj, err := wr.GetObject(r.Context(), k8sClient, ns, r.PathValue("name"))
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					http.Error(w, "Resource not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("Failed to get resource", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// The whole set rendered by the template is deleted
+			err = wrap.DeleteObjects(r.Context(), k8sClient, wrap.OwnedObjects(obj), obj.GetLabels()[wrap.OwnerLabel])
+			recorder.Record(r.Context(), newAuditRecord(r, wr, audit.OpDelete, auditRef(obj, obj, nil), obj, nil, err))
+			if err != nil {
+				logger.Error("Failed to delete resources", "error", err, "wrap", wr.Name)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		mux.HandleFunc("GET /api/v1/audit", func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			identity := auth.FromContext(r.Context())
+			filter := &audit.Filter{
+				Wrap: query.Get("wrap"),
+				User: query.Get("user"),
+				Name: query.Get("name"),
+				// Only records of wraps the user can view
+				Accept: func(record *audit.Record) bool {
+					wr := store.GetWrap(record.Wrap)
+					return wr != nil && wr.UserOperations(identity).View
+				},
+			}
+			if limit := query.Get("limit"); limit != "" {
+				value, err := strconv.Atoi(limit)
+				if err != nil || value < 0 {
+					http.Error(w, "Invalid limit", http.StatusBadRequest)
+					return
+				}
+				filter.Limit = value
+			}
 			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(list.Items); err != nil {
+			if err := json.NewEncoder(w).Encode(recorder.Recent(filter)); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
 		})
 
-		httpServer := httpsrv.New("krapper", &serveParams.httpConfig, mux)
+		httpServer := httpsrv.New("krapper", &serveParams.httpConfig, handler)
 
 		if err := httpServer.Start(ctx); err != nil {
 			logger.Error("Error starting HTTP server", "error", err)
@@ -138,3 +779,86 @@ var serveCmd = &cobra.Command{
 		}
 	},
 }
+
+// viewableWraps return a lookup of the wraps the identity can view. Others are reported as unknown,
+// so that their objects are never listed on behalf of another wrap (references, related objects).
+func viewableWraps(store wrapstore.WrapStore, identity *auth.Identity) wrap.WrapLookup {
+	return func(name string) *wrap.Wrap {
+		if target := store.GetWrap(name); target != nil && target.UserOperations(identity).View {
+			return target
+		}
+		return nil
+	}
+}
+
+// httpValidationError send a *wrap.ValidationError as a json payload. Other errors are handled as internal ones.
+func httpValidationError(w http.ResponseWriter, err error) {
+	var validationError *wrap.ValidationError
+	if !errors.As(err, &validationError) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(validationError)
+}
+
+// requestLocales return the locales accepted by the client, by decreasing preference
+func requestLocales(r *http.Request) []string {
+	return misc.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// newAuditRecord build the audit record of an operation performed by the request identity. err is the operation outcome.
+func newAuditRecord(r *http.Request, wr *wrap.Wrap, operation audit.Operation, ref audit.ObjectRef, before *unstructured.Unstructured, after *unstructured.Unstructured, err error) *audit.Record {
+	identity := auth.FromContext(r.Context())
+	record := &audit.Record{
+		User:      identity.Login,
+		Groups:    identity.Groups,
+		Wrap:      wr.Name,
+		Object:    ref,
+		Operation: operation,
+		Outcome:   audit.OutcomeSuccess,
+	}
+	if err != nil {
+		record.Outcome = audit.OutcomeFailure
+		record.Error = err.Error()
+	} else {
+		record.Changes = audit.Diff(auditObject(wr, before), auditObject(wr, after))
+		// Masked secrets are absent of both versions: their changes are reported without the values
+		for _, change := range wr.SecretChanges(before, after) {
+			record.Changes = append(record.Changes, audit.Change{Path: change.Path, Before: change.Before, After: change.After})
+		}
+		sort.Slice(record.Changes, func(i, j int) bool {
+			return record.Changes[i].Path < record.Changes[j].Path
+		})
+	}
+	return record
+}
+
+// auditRef return the reference of target. uid is taken from the first non nil of the candidates (i.e. existing or applied object)
+func auditRef(target *unstructured.Unstructured, candidates ...*unstructured.Unstructured) audit.ObjectRef {
+	ref := audit.ObjectRef{
+		ApiVersion: target.GetAPIVersion(),
+		Kind:       target.GetKind(),
+		Namespace:  target.GetNamespace(),
+		Name:       target.GetName(),
+	}
+	for _, candidate := range candidates {
+		if candidate != nil && candidate.GetUID() != "" {
+			ref.Uid = string(candidate.GetUID())
+			break
+		}
+	}
+	return ref
+}
+
+// auditObject return the content of obj, with secrets masked and without decoration. nil if obj is nil
+func auditObject(wr *wrap.Wrap, obj *unstructured.Unstructured) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	masked := obj.DeepCopy()
+	wr.MaskSecrets(masked)
+	delete(masked.Object, wrap.DecorationKey)
+	return masked.Object
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Operation string
+
+const (
+	OpCreate Operation = "create"
+	OpUpdate Operation = "update"
+	OpDelete Operation = "delete"
+	OpAction Operation = "action"
+)
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// ObjectRef identify the object targeted by an operation
+type ObjectRef struct {
+	ApiVersion string `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string `yaml:"kind" json:"kind"`
+	Namespace  string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Name       string `yaml:"name" json:"name"`
+	Uid        string `yaml:"uid,omitempty" json:"uid,omitempty"`
+}
+
+// Record is an audit entry, for a mutation performed through the API
+type Record struct {
+	Timestamp time.Time `yaml:"timestamp" json:"timestamp"`
+	User      string    `yaml:"user" json:"user"`
+	Groups    []string  `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Wrap      string    `yaml:"wrap" json:"wrap"`
+	Object    ObjectRef `yaml:"object" json:"object"`
+	Operation Operation `yaml:"operation" json:"operation"`
+	Action    string    `yaml:"action,omitempty" json:"action,omitempty"` // For OpAction
+	Changes   []Change  `yaml:"changes,omitempty" json:"changes,omitempty"`
+	Outcome   Outcome   `yaml:"outcome" json:"outcome"`
+	Error     string    `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// Sink is a destination of audit records
+type Sink interface {
+	Write(ctx context.Context, record *Record) error
+}
+
+// Filter select records returned by Recorder.Recent(). Empty members match all.
+type Filter struct {
+	Wrap  string
+	User  string
+	Name  string
+	Limit int // Default to all kept records
+	// Optional. i.e. to restrict records to the ones the requester may see
+	Accept func(record *Record) bool
+}
+
+func (f *Filter) match(record *Record) bool {
+	return (f.Wrap == "" || f.Wrap == record.Wrap) && (f.User == "" || f.User == record.User) && (f.Name == "" || f.Name == record.Object.Name) &&
+		(f.Accept == nil || f.Accept(record))
+}
+
+// Recorder dispatch records to all sinks, and keep the most recent ones in memory, for querying.
+type Recorder struct {
+	sinks  []Sink
+	logger *slog.Logger
+	mu     sync.Mutex
+	recent []Record // Ring buffer
+	next   int
+	full   bool
+}
+
+// NewRecorder build a Recorder keeping the keep most recent records.
+func NewRecorder(logger *slog.Logger, keep int, sinks ...Sink) *Recorder {
+	if keep <= 0 {
+		keep = 1
+	}
+	return &Recorder{
+		sinks:  sinks,
+		logger: logger,
+		recent: make([]Record, keep),
+	}
+}
+
+// Record set the timestamp, then write the record to all sinks. Sink errors are logged, as they must not fail the operation.
+func (r *Recorder) Record(ctx context.Context, record *Record) {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now().UTC()
+	}
+	r.mu.Lock()
+	r.recent[r.next] = *record
+	r.next = (r.next + 1) % len(r.recent)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+	for _, sink := range r.sinks {
+		if err := sink.Write(ctx, record); err != nil {
+			r.logger.Error("Unable to write audit record", "error", err, "wrap", record.Wrap, "name", record.Object.Name, "operation", record.Operation)
+		}
+	}
+}
+
+// Recent return the kept records matching the filter, most recent first
+func (r *Recorder) Recent(filter *Filter) []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.recent)
+	}
+	result := make([]Record, 0)
+	for i := 0; i < count; i++ {
+		idx := (r.next - 1 - i + len(r.recent)) % len(r.recent)
+		if !filter.match(&r.recent[idx]) {
+			continue
+		}
+		result = append(result, r.recent[idx])
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "jdoe",
+			"resourceVersion": "12",
+			"labels":          map[string]interface{}{"team": "a"},
+		},
+		"spec": map[string]interface{}{
+			"name":   "John",
+			"groups": []interface{}{"admin"},
+			"uid":    int64(1000),
+		},
+		"status": map[string]interface{}{"ready": false},
+	}
+	after := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "jdoe",
+			"resourceVersion": "13",
+		},
+		"spec": map[string]interface{}{
+			"name":     "John Doe",
+			"groups":   []interface{}{"admin", "dev"},
+			"uid":      int64(1000),
+			"disabled": true,
+		},
+		"status": map[string]interface{}{"ready": true},
+	}
+	expected := []Change{
+		{Path: "metadata.labels.team", Before: "a"},
+		{Path: "spec.disabled", After: true},
+		{Path: "spec.groups", Before: []interface{}{"admin"}, After: []interface{}{"admin", "dev"}},
+		{Path: "spec.name", Before: "John", After: "John Doe"},
+	}
+	if changes := Diff(before, after); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+	// Creation
+	changes := Diff(nil, map[string]interface{}{"spec": map[string]interface{}{"name": "John"}})
+	if len(changes) != 1 || changes[0].Path != "spec.name" || changes[0].Before != nil || changes[0].After != "John" {
+		t.Errorf("unexpected creation changes: %v", changes)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(slog.Default(), 3)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		recorder.Record(context.Background(), &Record{User: "jdoe", Wrap: "users", Object: ObjectRef{Name: name}, Operation: OpUpdate, Outcome: OutcomeSuccess})
+	}
+	recorder.Record(context.Background(), &Record{User: "admin", Wrap: "groups", Object: ObjectRef{Name: "e"}, Operation: OpCreate, Outcome: OutcomeSuccess})
+	names := func(records []Record) []string {
+		result := make([]string, 0, len(records))
+		for _, record := range records {
+			if record.Timestamp.IsZero() {
+				t.Errorf("record %s has no timestamp", record.Object.Name)
+			}
+			result = append(result, record.Object.Name)
+		}
+		return result
+	}
+	if got := names(recorder.Recent(&Filter{})); !reflect.DeepEqual(got, []string{"e", "d", "c"}) {
+		t.Errorf("unexpected recent records: %v", got)
+	}
+	if got := names(recorder.Recent(&Filter{Wrap: "users"})); !reflect.DeepEqual(got, []string{"d", "c"}) {
+		t.Errorf("unexpected records for wrap: %v", got)
+	}
+	if got := names(recorder.Recent(&Filter{User: "jdoe", Limit: 1})); !reflect.DeepEqual(got, []string{"d"}) {
+		t.Errorf("unexpected limited records: %v", got)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	record := &Record{User: "jdoe", Wrap: "users", Object: ObjectRef{Name: "jdoe"}, Operation: OpDelete, Outcome: OutcomeSuccess}
+	data, _ := json.Marshal(record)
+	// Room for two records per file
+	sink, err := NewFileSink(path, int64(2*(len(data)+1)), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	countLines := func(path string) int {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("unable to open '%s': %v", path, err)
+		}
+		defer func() { _ = file.Close() }()
+		count := 0
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var decoded Record
+			if err := json.Unmarshal(scanner.Bytes(), &decoded); err != nil {
+				t.Errorf("invalid line in '%s': %v", path, err)
+			}
+			count++
+		}
+		return count
+	}
+	if count := countLines(path); count != 1 {
+		t.Errorf("expected 1 record in current file, got %d", count)
+	}
+	if count := countLines(path + ".1"); count != 2 {
+		t.Errorf("expected 2 records in first backup, got %d", count)
+	}
+	if count := countLines(path + ".2"); count != 2 {
+		t.Errorf("expected 2 records in second backup, got %d", count)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected no third backup")
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	record := &Record{
+		User:      "jdoe",
+		Object:    ObjectRef{ApiVersion: "v1", Kind: "Namespace", Name: "team-a", Uid: "u1"},
+		Operation: OpAction,
+		Action:    "disable",
+		Outcome:   OutcomeFailure,
+		Error:     "forbidden",
+	}
+	event := newEvent(record)
+	if event.GetNamespace() != "default" {
+		t.Errorf("expected event of cluster scoped object in 'default' namespace, got '%s'", event.GetNamespace())
+	}
+	if event.Object["type"] != "Warning" || event.Object["reason"] != "KrapperDisableFailed" {
+		t.Errorf("unexpected event type/reason: %v/%v", event.Object["type"], event.Object["reason"])
+	}
+	if event.Object["message"] != "disable by jdoe failed: forbidden" {
+		t.Errorf("unexpected message: %v", event.Object["message"])
+	}
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is a modified value, at a dotted path (i.e. 'spec.groups'). Before or After is nil for an added or removed value.
+type Change struct {
+	Path   string      `yaml:"path" json:"path"`
+	Before interface{} `yaml:"before,omitempty" json:"before,omitempty"`
+	After  interface{} `yaml:"after,omitempty" json:"after,omitempty"`
+}
+
+// ignoredPaths are server managed values, which are not part of the changes performed by the user
+var ignoredPaths = map[string]bool{
+	"status":                     true,
+	"metadata.managedFields":     true,
+	"metadata.resourceVersion":   true,
+	"metadata.generation":        true,
+	"metadata.uid":               true,
+	"metadata.creationTimestamp": true,
+}
+
+// Diff return the changes between two versions of an object, sorted by path. before or after may be nil.
+// Maps are compared recursively, other values (including arrays) as a whole.
+func Diff(before map[string]interface{}, after map[string]interface{}) []Change {
+	changes := make([]Change, 0)
+	diffMaps("", before, after, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func diffMaps(path string, before map[string]interface{}, after map[string]interface{}, changes *[]Change) {
+	keys := make(map[string]bool, len(before)+len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	for k := range keys {
+		childPath := k
+		if path != "" {
+			childPath = strings.Join([]string{path, k}, ".")
+		}
+		if ignoredPaths[childPath] {
+			continue
+		}
+		b, a := before[k], after[k]
+		bm, bIsMap := b.(map[string]interface{})
+		am, aIsMap := a.(map[string]interface{})
+		if bIsMap && aIsMap || bIsMap && a == nil || aIsMap && b == nil {
+			diffMaps(childPath, bm, am, changes)
+			continue
+		}
+		if !reflect.DeepEqual(b, a) {
+			*changes = append(*changes, Change{Path: childPath, Before: b, After: a})
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"krapper/internal/k8s"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FileSink write records as JSON lines. The file is rotated when reaching maxSize bytes: 'audit.log' is renamed
+// 'audit.log.1', previous 'audit.log.1' is renamed 'audit.log.2' and so on, up to maxBackups.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create audit folder: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open audit file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("unable to stat audit file: %w", err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.maxBackups > 0 {
+		for idx := s.maxBackups - 1; idx > 0; idx-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", s.path, idx), fmt.Sprintf("%s.%d", s.path, idx+1))
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) Write(_ context.Context, record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("unable to rotate audit file: %w", err)
+		}
+	}
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+// LoggerSink write records as structured log entries
+type LoggerSink struct {
+	logger *slog.Logger
+}
+
+func NewLoggerSink(logger *slog.Logger) *LoggerSink {
+	return &LoggerSink{logger: logger}
+}
+
+func (s *LoggerSink) Write(ctx context.Context, record *Record) error {
+	level := slog.LevelInfo
+	if record.Outcome == OutcomeFailure {
+		level = slog.LevelWarn
+	}
+	s.logger.Log(ctx, level, "Audit",
+		"user", record.User,
+		"wrap", record.Wrap,
+		"kind", record.Object.Kind,
+		"namespace", record.Object.Namespace,
+		"name", record.Object.Name,
+		"operation", record.Operation,
+		"action", record.Action,
+		"changes", len(record.Changes),
+		"outcome", record.Outcome,
+		"error", record.Error,
+	)
+	return nil
+}
+
+// EventSink write records as Kubernetes Events on the targeted object
+type EventSink struct {
+	client k8s.Client
+}
+
+func NewEventSink(client k8s.Client) *EventSink {
+	return &EventSink{client: client}
+}
+
+func (s *EventSink) Write(ctx context.Context, record *Record) error {
+	_, err := s.client.ApplyResource(ctx, newEvent(record))
+	return err
+}
+
+// newEvent build the core/v1 Event reporting a record. Events of cluster scoped objects are in the 'default' namespace.
+func newEvent(record *Record) *unstructured.Unstructured {
+	ns := record.Object.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+	operation := string(record.Operation)
+	if record.Action != "" {
+		operation = record.Action
+	}
+	eventType, reason := "Normal", "Krapper"+strings.ToUpper(operation[:1])+operation[1:]
+	message := fmt.Sprintf("%s by %s", operation, record.User)
+	if record.Outcome == OutcomeFailure {
+		eventType, reason = "Warning", reason+"Failed"
+		message = fmt.Sprintf("%s by %s failed: %s", operation, record.User, record.Error)
+	}
+	if len(record.Changes) > 0 {
+		paths := make([]string, 0, len(record.Changes))
+		for _, change := range record.Changes {
+			paths = append(paths, change.Path)
+		}
+		message += ". Changed: " + strings.Join(paths, ", ")
+	}
+	timestamp := record.Timestamp.UTC().Format(time.RFC3339)
+	event := &unstructured.Unstructured{Object: map[string]interface{}{
+		"involvedObject": map[string]interface{}{
+			"apiVersion": record.Object.ApiVersion,
+			"kind":       record.Object.Kind,
+			"namespace":  record.Object.Namespace,
+			"name":       record.Object.Name,
+			"uid":        record.Object.Uid,
+		},
+		"type":           eventType,
+		"reason":         reason,
+		"message":        message,
+		"source":         map[string]interface{}{"component": k8s.FieldManager},
+		"count":          int64(1),
+		"firstTimestamp": timestamp,
+		"lastTimestamp":  timestamp,
+	}}
+	event.SetAPIVersion("v1")
+	event.SetKind("Event")
+	event.SetNamespace(ns)
+	event.SetName(fmt.Sprintf("%s.%x", record.Object.Name, record.Timestamp.UnixNano()))
+	return event
+}
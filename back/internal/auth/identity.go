@@ -0,0 +1,27 @@
+package auth
+
+import "context"
+
+// AnonymousLogin is the login of requests without authenticated user
+const AnonymousLogin = "anonymous"
+
+// Identity is the user performing a request
+type Identity struct {
+	Login  string   `yaml:"login" json:"login"`
+	Groups []string `yaml:"groups" json:"groups"`
+}
+
+type identityKey struct{}
+
+// NewContext return a context carrying the identity
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext return the identity of the request, or an anonymous one
+func FromContext(ctx context.Context) *Identity {
+	if identity, ok := ctx.Value(identityKey{}).(*Identity); ok && identity != nil {
+		return identity
+	}
+	return &Identity{Login: AnonymousLogin, Groups: []string{}}
+}
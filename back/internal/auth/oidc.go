@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	SessionCookie = "krapper_session"
+	loginCookie   = "krapper_login"
+	loginTtl      = 10 * time.Minute
+	// AuthPath is the prefix of the login flow endpoints, which are reachable without session
+	AuthPath = "/api/v1/auth/"
+)
+
+type OidcConfig struct {
+	Issuer       string   `yaml:"issuer"`
+	ClientId     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	RedirectUrl  string   `yaml:"redirectUrl"` // Must point to .../api/v1/auth/callback
+	Scopes       []string `yaml:"scopes"`      // 'openid' is always added
+	LoginClaim   string   `yaml:"loginClaim"`  // Default to 'preferred_username'. Fallback to 'sub' if missing
+	GroupsClaim  string   `yaml:"groupsClaim"` // Default to 'groups'
+	GroupsPrefix string   `yaml:"groupsPrefix"`
+	// Logout redirect the browser to the provider end_session_endpoint (if any), which redirect to PostLogoutUrl
+	PostLogoutUrl string        `yaml:"postLogoutUrl"`
+	SessionKey    string        `yaml:"sessionKey"` // Random if empty. Sessions will then be lost on restart
+	SessionTtl    time.Duration `yaml:"sessionTtl"`
+}
+
+// Authenticator perform the OIDC authorization code flow (with PKCE), and maintain the resulting Identity in an encrypted session cookie.
+type Authenticator struct {
+	config     *OidcConfig
+	oauth2     *oauth2.Config
+	verifier   *oidc.IDTokenVerifier
+	endSession string
+	codec      *sessionCodec
+	secure     bool
+	logger     *slog.Logger
+}
+
+// loginState is stored in a short-lived cookie, between the login redirect and the callback
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+// NewAuthenticator fetch the provider configuration (discovery). ctx must outlive the Authenticator.
+func NewAuthenticator(ctx context.Context, config *OidcConfig, logger *slog.Logger) (*Authenticator, error) {
+	if config.ClientId == "" || config.RedirectUrl == "" {
+		return nil, errors.New("clientId and redirectUrl are required")
+	}
+	redirectUrl, err := url.Parse(config.RedirectUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirectUrl: %w", err)
+	}
+	if config.LoginClaim == "" {
+		config.LoginClaim = "preferred_username"
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.SessionTtl <= 0 {
+		config.SessionTtl = 8 * time.Hour
+	}
+	if config.SessionKey == "" {
+		logger.Warn("No session key provided. Sessions will not survive a restart")
+	}
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to discover OIDC provider '%s': %w", config.Issuer, err)
+	}
+	var discovery struct {
+		EndSession string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return nil, fmt.Errorf("unable to decode discovery document: %w", err)
+	}
+	scopes := []string{oidc.ScopeOpenID}
+	for _, scope := range config.Scopes {
+		if scope != oidc.ScopeOpenID {
+			scopes = append(scopes, scope)
+		}
+	}
+	codec, err := newSessionCodec(config.SessionKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		config: config,
+		oauth2: &oauth2.Config{
+			ClientID:     config.ClientId,
+			ClientSecret: config.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  config.RedirectUrl,
+			Scopes:       scopes,
+		},
+		verifier:   provider.Verifier(&oidc.Config{ClientID: config.ClientId}),
+		endSession: discovery.EndSession,
+		codec:      codec,
+		secure:     redirectUrl.Scheme == "https",
+		logger:     logger,
+	}, nil
+}
+
+// Register add the login flow endpoints to the mux
+func (a *Authenticator) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+AuthPath+"login", a.login)
+	mux.HandleFunc("GET "+AuthPath+"callback", a.callback)
+	mux.HandleFunc("GET "+AuthPath+"logout", a.logout)
+	mux.HandleFunc("POST "+AuthPath+"logout", a.logout)
+}
+
+// Middleware inject the session Identity in the request context. API requests without a valid session are rejected (401),
+// except the login flow ones.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity := a.session(r); identity != nil {
+			r = r.WithContext(NewContext(r.Context(), identity))
+		} else if strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, AuthPath) {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) session(r *http.Request) *Identity {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return nil
+	}
+	var identity Identity
+	if err := a.codec.decode(SessionCookie, cookie.Value, &identity, time.Now()); err != nil {
+		a.logger.Debug("Invalid session cookie", "error", err)
+		return nil
+	}
+	return &identity
+}
+
+func (a *Authenticator) cookie(name string, path string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Path:     path,
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (a *Authenticator) login(w http.ResponseWriter, r *http.Request) {
+	state := &loginState{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: oauth2.GenerateVerifier(),
+		Redirect: safeRedirect(r.URL.Query().Get("redirect")),
+	}
+	if err := a.codec.setCookie(w, a.cookie(loginCookie, AuthPath), state, loginTtl); err != nil {
+		a.logger.Error("Unable to set login cookie", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, a.oauth2.AuthCodeURL(state.State, oidc.Nonce(state.Nonce), oauth2.S256ChallengeOption(state.Verifier)), http.StatusFound)
+}
+
+func (a *Authenticator) callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		a.logger.Warn("Login refused by provider", "error", errCode, "description", query.Get("error_description"))
+		http.Error(w, fmt.Sprintf("Login failed: %s", errCode), http.StatusUnauthorized)
+		return
+	}
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		http.Error(w, "No login in progress", http.StatusBadRequest)
+		return
+	}
+	var state loginState
+	if err := a.codec.decode(loginCookie, cookie.Value, &state, time.Now()); err != nil || state.State != query.Get("state") {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	_ = a.codec.setCookie(w, a.cookie(loginCookie, AuthPath), nil, 0)
+	token, err := a.oauth2.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(state.Verifier))
+	if err != nil {
+		a.logger.Warn("Unable to exchange authorization code", "error", err)
+		http.Error(w, "Unable to exchange authorization code", http.StatusUnauthorized)
+		return
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "No id_token in token response", http.StatusUnauthorized)
+		return
+	}
+	idToken, err := a.verifier.Verify(r.Context(), rawIdToken)
+	if err != nil {
+		a.logger.Warn("Invalid id_token", "error", err)
+		http.Error(w, "Invalid id_token", http.StatusUnauthorized)
+		return
+	}
+	if idToken.Nonce != state.Nonce {
+		http.Error(w, "Invalid id_token nonce", http.StatusUnauthorized)
+		return
+	}
+	identity, err := a.identity(idToken)
+	if err != nil {
+		a.logger.Warn("Unable to build identity from id_token", "error", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := a.codec.setCookie(w, a.cookie(SessionCookie, "/"), identity, a.config.SessionTtl); err != nil {
+		a.logger.Error("Unable to set session cookie", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.logger.Info("User logged in", "user", identity.Login, "groups", identity.Groups)
+	http.Redirect(w, r, state.Redirect, http.StatusFound)
+}
+
+// identity map the id_token claims to an Identity
+func (a *Authenticator) identity(idToken *oidc.IDToken) (*Identity, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	login, _ := claims[a.config.LoginClaim].(string)
+	if login == "" {
+		login = idToken.Subject
+	}
+	if login == "" {
+		return nil, fmt.Errorf("no '%s' nor 'sub' claim in id_token", a.config.LoginClaim)
+	}
+	groups := make([]string, 0)
+	switch value := claims[a.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range value {
+			if g, ok := group.(string); ok && g != "" {
+				groups = append(groups, a.config.GroupsPrefix+g)
+			}
+		}
+	case string:
+		if value != "" {
+			groups = append(groups, a.config.GroupsPrefix+value)
+		}
+	}
+	return &Identity{Login: login, Groups: groups}, nil
+}
+
+func (a *Authenticator) logout(w http.ResponseWriter, r *http.Request) {
+	_ = a.codec.setCookie(w, a.cookie(SessionCookie, "/"), nil, 0)
+	target := a.config.PostLogoutUrl
+	if a.endSession != "" {
+		values := url.Values{"client_id": {a.config.ClientId}}
+		if a.config.PostLogoutUrl != "" {
+			values.Set("post_logout_redirect_uri", a.config.PostLogoutUrl)
+		}
+		target = a.endSession + "?" + values.Encode()
+	}
+	if target == "" {
+		target = "/"
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// safeRedirect only allow local paths, to prevent open redirects
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
+
+func randomString() string {
+	data := make([]byte, 24)
+	_, _ = rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockProvider is a minimal OIDC provider, issuing RS256 id_tokens for a fixed user
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	codes  map[string]url.Values // Authorization request, by code
+	claims map[string]interface{}
+}
+
+func newMockProvider(t *testing.T, claims map[string]interface{}) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	p := &mockProvider{key: key, codes: make(map[string]url.Values), claims: claims}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"end_session_endpoint":                  p.server.URL + "/logout",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{map[string]interface{}{
+			"kty": "RSA",
+			"kid": "k1",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("GET /authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := randomString()
+		p.mu.Lock()
+		p.codes[code] = query
+		p.mu.Unlock()
+		http.Redirect(w, r, query.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {query.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		p.mu.Lock()
+		request, ok := p.codes[r.Form.Get("code")]
+		delete(p.codes, r.Form.Get("code"))
+		p.mu.Unlock()
+		if !ok {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		// PKCE
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if request.Get("code_challenge_method") != "S256" || base64.RawURLEncoding.EncodeToString(sum[:]) != request.Get("code_challenge") {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		clientId, _, _ := r.BasicAuth()
+		idToken := p.sign(t, clientId, request.Get("nonce"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) sign(t *testing.T, audience string, nonce string) string {
+	claims := map[string]interface{}{
+		"iss":   p.server.URL,
+		"aud":   audience,
+		"sub":   "0001",
+		"nonce": nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("unable to sign token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func findCookie(resp *http.Response, name string) *http.Cookie {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOidcLogin(t *testing.T) {
+	provider := newMockProvider(t, map[string]interface{}{
+		"preferred_username": "jdoe",
+		"groups":             []interface{}{"admin", "dev"},
+	})
+	authenticator, err := NewAuthenticator(context.Background(), &OidcConfig{
+		Issuer:        provider.server.URL,
+		ClientId:      "krapper",
+		ClientSecret:  "secret",
+		RedirectUrl:   "http://krapper.local/api/v1/auth/callback",
+		GroupsPrefix:  "oidc:",
+		PostLogoutUrl: "http://krapper.local/",
+		SessionKey:    "a session key",
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mux := http.NewServeMux()
+	authenticator.Register(mux)
+	mux.HandleFunc("GET /api/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(FromContext(r.Context()))
+	})
+	handler := authenticator.Middleware(mux)
+	serve := func(method string, target string, cookie *http.Cookie) *http.Response {
+		req := httptest.NewRequest(method, target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Result()
+	}
+
+	if resp := serve("GET", "/api/v1/me", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without session, got %d", resp.StatusCode)
+	}
+
+	// Login redirect to the provider, with PKCE
+	resp := serve("GET", "/api/v1/auth/login?redirect=/wraps/users", nil)
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected login redirect, got %d", resp.StatusCode)
+	}
+	authorize, _ := url.Parse(resp.Header.Get("Location"))
+	if !strings.HasPrefix(authorize.String(), provider.server.URL+"/authorize") || authorize.Query().Get("code_challenge") == "" {
+		t.Fatalf("unexpected authorize url: %s", authorize)
+	}
+	loginState := findCookie(resp, loginCookie)
+	if loginState == nil {
+		t.Fatalf("no login cookie")
+	}
+
+	// The provider redirect to the callback
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	idpResp, err := client.Get(authorize.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	callback, _ := url.Parse(idpResp.Header.Get("Location"))
+
+	// A forged state is rejected
+	forged := callback.Query()
+	forged.Set("state", "forged")
+	if resp := serve("GET", "/api/v1/auth/callback?"+forged.Encode(), loginState); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 on forged state, got %d", resp.StatusCode)
+	}
+
+	resp = serve("GET", callback.RequestURI(), loginState)
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/wraps/users" {
+		t.Fatalf("expected redirect to original page, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	session := findCookie(resp, SessionCookie)
+	if session == nil || !session.HttpOnly {
+		t.Fatalf("expected an http only session cookie")
+	}
+
+	resp = serve("GET", "/api/v1/me", session)
+	var identity Identity
+	if err := json.NewDecoder(resp.Body).Decode(&identity); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.Login != "jdoe" || strings.Join(identity.Groups, ",") != "oidc:admin,oidc:dev" {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+
+	// The login cookie can't be used as a session
+	if resp := serve("GET", "/api/v1/me", &http.Cookie{Name: SessionCookie, Value: loginState.Value}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 with login cookie as session, got %d", resp.StatusCode)
+	}
+	// Tampered session
+	if resp := serve("GET", "/api/v1/me", &http.Cookie{Name: SessionCookie, Value: session.Value[:len(session.Value)-2] + "AA"}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 with tampered session, got %d", resp.StatusCode)
+	}
+
+	resp = serve("POST", "/api/v1/auth/logout", session)
+	if cleared := findCookie(resp, SessionCookie); cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("expected session cookie to be cleared")
+	}
+	if location := resp.Header.Get("Location"); !strings.HasPrefix(location, provider.server.URL+"/logout?") || !strings.Contains(location, "post_logout_redirect_uri=") {
+		t.Errorf("unexpected logout redirect: %s", location)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	for redirect, expected := range map[string]string{
+		"":                    "/",
+		"/wraps/users":        "/wraps/users",
+		"//evil.com":          "/",
+		"/\\evil.com":         "/",
+		"https://evil.com/x":  "/",
+		"javascript:alert(1)": "/",
+	} {
+		if got := safeRedirect(redirect); got != expected {
+			t.Errorf("safeRedirect(%q): expected %q, got %q", redirect, expected, got)
+		}
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	codec, err := newSessionCodec("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	value, err := codec.encode(SessionCookie, &Identity{Login: "jdoe"}, time.Minute, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var identity Identity
+	if err := codec.decode(SessionCookie, value, &identity, now.Add(30*time.Second)); err != nil || identity.Login != "jdoe" {
+		t.Errorf("expected valid session, got %v", err)
+	}
+	if err := codec.decode(SessionCookie, value, &identity, now.Add(2*time.Minute)); err == nil {
+		t.Errorf("expected expired session")
+	}
+}
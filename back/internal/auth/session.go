@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// sessionCodec encrypt and authenticate cookie values (AES-256-GCM), with an embedded expiry
+type sessionCodec struct {
+	aead cipher.AEAD
+}
+
+// newSessionCodec build a codec from a secret. If empty, a random key is generated, thus sessions will not survive a restart.
+func newSessionCodec(secret string) (*sessionCodec, error) {
+	key := make([]byte, 32)
+	if secret == "" {
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	} else {
+		sum := sha256.Sum256([]byte(secret))
+		key = sum[:]
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionCodec{aead: aead}, nil
+}
+
+type envelope struct {
+	Name    string          `json:"n"` // The cookie name, to prevent a value to be replayed in another cookie
+	Expires int64           `json:"e"`
+	Payload json.RawMessage `json:"p"`
+}
+
+func (c *sessionCodec) encode(name string, payload interface{}, ttl time.Duration, now time.Time) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(&envelope{Name: name, Expires: now.Add(ttl).Unix(), Payload: raw})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+func (c *sessionCodec) decode(name string, value string, payload interface{}, now time.Time) error {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	if len(data) < c.aead.NonceSize() {
+		return errors.New("value too short")
+	}
+	data, err = c.aead.Open(nil, data[:c.aead.NonceSize()], data[c.aead.NonceSize():], nil)
+	if err != nil {
+		return err
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	if env.Name != name {
+		return fmt.Errorf("value of cookie '%s' used as '%s'", env.Name, name)
+	}
+	if now.Unix() >= env.Expires {
+		return errors.New("expired")
+	}
+	return json.Unmarshal(env.Payload, payload)
+}
+
+// setCookie write an encoded cookie. A ttl of zero delete the cookie
+func (c *sessionCodec) setCookie(w http.ResponseWriter, cookie *http.Cookie, payload interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		cookie.Value, cookie.MaxAge = "", -1
+		http.SetCookie(w, cookie)
+		return nil
+	}
+	value, err := c.encode(cookie.Name, payload, ttl, time.Now())
+	if err != nil {
+		return err
+	}
+	cookie.Value, cookie.MaxAge = value, int(ttl.Seconds())
+	http.SetCookie(w, cookie)
+	return nil
+}
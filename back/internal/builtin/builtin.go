@@ -0,0 +1,7 @@
+// Package builtin hosts the default wrap set, compiled into the binary.
+package builtin
+
+import "embed"
+
+//go:embed wraps
+var Wraps embed.FS
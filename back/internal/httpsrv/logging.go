@@ -67,6 +67,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap allows http.ResponseController to reach the underlying writer (i.e. to flush streamed responses)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 var globalExchangeCount int64 = 0
 
 type requestLog struct {
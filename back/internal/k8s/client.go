@@ -11,6 +11,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
@@ -21,8 +22,24 @@ import (
 
 type Client interface {
 	ListResources(ctx context.Context, apiVersion, kind, namespace string, selector map[string]string) (*unstructured.UnstructuredList, error)
+	// GetResource returns a NotFound error (Use k8s.io/apimachinery/pkg/api/errors.IsNotFound()) if the object does not exist
+	GetResource(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error)
+	// ApplyResource create or update the object, using server side apply. Ownership is not forced: a conflict with
+	// fields owned by another manager fails (Use k8s.io/apimachinery/pkg/api/errors.IsConflict())
+	ApplyResource(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	// PatchResource apply a json merge patch to an existing object
+	PatchResource(ctx context.Context, apiVersion, kind, namespace, name string, patch []byte) (*unstructured.Unstructured, error)
+	// DeleteResource returns a NotFound error if the object does not exist
+	DeleteResource(ctx context.Context, apiVersion, kind, namespace, name string) error
+	// ListEvents return the Events regarding the object of this uid, and the resourceVersion to watch from. See events.go
+	ListEvents(ctx context.Context, namespace string, uid string) ([]Event, string, error)
+	// WatchEvents stream the Events regarding the object of this uid, after resourceVersion, until ctx is done
+	WatchEvents(ctx context.Context, namespace string, uid string, resourceVersion string) (<-chan Event, error)
 }
 
+// FieldManager is the manager name used for server side apply
+const FieldManager = "krapper"
+
 type client struct {
 	dynamic   dynamic.Interface
 	discovery discovery.DiscoveryInterface
@@ -63,7 +80,8 @@ func NewClient(logger *slog.Logger) (Client, error) {
 	}, nil
 }
 
-func (c *client) ListResources(ctx context.Context, apiVersion, kind, namespace string, selector map[string]string) (*unstructured.UnstructuredList, error) {
+// resourceInterface resolve the GVR of apiVersion/kind. An empty namespace means all namespaces for a namespaced resource
+func (c *client) resourceInterface(apiVersion, kind, namespace string) (dynamic.ResourceInterface, error) {
 	// Parse GroupVersion
 	gv, err := schema.ParseGroupVersion(apiVersion)
 	if err != nil {
@@ -77,11 +95,16 @@ func (c *client) ListResources(ctx context.Context, apiVersion, kind, namespace
 		return nil, fmt.Errorf("failed to find REST mapping for %s: %w", gvk, err)
 	}
 
-	var res dynamic.ResourceInterface
 	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && namespace != "" {
-		res = c.dynamic.Resource(mapping.Resource).Namespace(namespace)
-	} else {
-		res = c.dynamic.Resource(mapping.Resource)
+		return c.dynamic.Resource(mapping.Resource).Namespace(namespace), nil
+	}
+	return c.dynamic.Resource(mapping.Resource), nil
+}
+
+func (c *client) ListResources(ctx context.Context, apiVersion, kind, namespace string, selector map[string]string) (*unstructured.UnstructuredList, error) {
+	res, err := c.resourceInterface(apiVersion, kind, namespace)
+	if err != nil {
+		return nil, err
 	}
 
 	opts := metav1.ListOptions{}
@@ -101,3 +124,43 @@ func (c *client) ListResources(ctx context.Context, apiVersion, kind, namespace
 
 	return list, nil
 }
+
+func (c *client) GetResource(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	res, err := c.resourceInterface(apiVersion, kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return res.Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *client) ApplyResource(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	res, err := c.resourceInterface(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := res.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: FieldManager})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply %s '%s': %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return applied, nil
+}
+
+func (c *client) DeleteResource(ctx context.Context, apiVersion, kind, namespace, name string) error {
+	res, err := c.resourceInterface(apiVersion, kind, namespace)
+	if err != nil {
+		return err
+	}
+	return res.Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (c *client) PatchResource(ctx context.Context, apiVersion, kind, namespace, name string, patch []byte) (*unstructured.Unstructured, error) {
+	res, err := c.resourceInterface(apiVersion, kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := res.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch %s '%s': %w", kind, name, err)
+	}
+	return patched, nil
+}
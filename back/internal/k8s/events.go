@@ -0,0 +1,163 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Event is the normalized form of core/v1 and events.k8s.io/v1 Events
+type Event struct {
+	Uid       string    `yaml:"uid" json:"uid"`
+	Type      string    `yaml:"type" json:"type"` // 'Normal' or 'Warning'
+	Reason    string    `yaml:"reason" json:"reason"`
+	Message   string    `yaml:"message" json:"message"`
+	Source    string    `yaml:"source,omitempty" json:"source,omitempty"` // Reporting component
+	Count     int64     `yaml:"count" json:"count"`
+	FirstTime time.Time `yaml:"firstTime" json:"firstTime"`
+	LastTime  time.Time `yaml:"lastTime" json:"lastTime"`
+}
+
+// eventFromObject normalize an Event of either API
+func eventFromObject(obj *unstructured.Unstructured) Event {
+	str := func(path ...string) string {
+		s, _, _ := unstructured.NestedString(obj.Object, path...)
+		return s
+	}
+	ts := func(path ...string) time.Time {
+		t, err := time.Parse(time.RFC3339Nano, str(path...))
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	}
+	event := Event{
+		Uid:     string(obj.GetUID()),
+		Type:    str("type"),
+		Reason:  str("reason"),
+		Message: str("message"),
+	}
+	if obj.GetAPIVersion() == "v1" {
+		event.Source = str("source", "component")
+		event.Count, _, _ = unstructured.NestedInt64(obj.Object, "count")
+		event.FirstTime = ts("firstTimestamp")
+		event.LastTime = ts("lastTimestamp")
+	} else {
+		event.Message = str("note")
+		event.Source = str("reportingController")
+		event.Count, _, _ = unstructured.NestedInt64(obj.Object, "series", "count")
+		event.FirstTime = ts("eventTime")
+		event.LastTime = ts("series", "lastObservedTime")
+		if event.Source == "" {
+			event.Source = str("deprecatedSource", "component")
+		}
+		if event.Message == "" {
+			event.Message = str("message")
+		}
+	}
+	if event.FirstTime.IsZero() {
+		event.FirstTime = ts("eventTime")
+	}
+	if event.FirstTime.IsZero() {
+		event.FirstTime = obj.GetCreationTimestamp().Time
+	}
+	if event.LastTime.IsZero() {
+		event.LastTime = event.FirstTime
+	}
+	if event.Count == 0 {
+		event.Count = 1
+	}
+	return event
+}
+
+// mergeEvents remove duplicates (The same Event is served by both APIs) and sort by last occurrence
+func mergeEvents(events []Event) []Event {
+	seen := make(map[string]bool, len(events))
+	result := make([]Event, 0, len(events))
+	for _, event := range events {
+		if event.Uid != "" && seen[event.Uid] {
+			continue
+		}
+		seen[event.Uid] = true
+		result = append(result, event)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastTime.Before(result[j].LastTime)
+	})
+	return result
+}
+
+// ListEvents return the Events regarding the object of this uid, sorted by last occurrence, and the resourceVersion
+// of the v1 list, to start a watch from. events.k8s.io/v1 Events are ignored if this API is not served.
+func (c *client) ListEvents(ctx context.Context, namespace string, uid string) ([]Event, string, error) {
+	events := make([]Event, 0)
+	resourceVersion := ""
+	for _, source := range []struct{ apiVersion, field string }{{"v1", "involvedObject.uid"}, {"events.k8s.io/v1", "regarding.uid"}} {
+		res, err := c.resourceInterface(source.apiVersion, "Event", namespace)
+		if err != nil {
+			if source.apiVersion == "v1" {
+				return nil, "", err
+			}
+			continue
+		}
+		list, err := res.List(ctx, metav1.ListOptions{FieldSelector: fmt.Sprintf("%s=%s", source.field, uid)})
+		if err != nil {
+			if source.apiVersion == "v1" {
+				return nil, "", fmt.Errorf("failed to list events: %w", err)
+			}
+			c.logger.Debug("Unable to list events.k8s.io events", "error", err)
+			continue
+		}
+		if source.apiVersion == "v1" {
+			resourceVersion = list.GetResourceVersion()
+		}
+		for idx := range list.Items {
+			events = append(events, eventFromObject(&list.Items[idx]))
+		}
+	}
+	return mergeEvents(events), resourceVersion, nil
+}
+
+// WatchEvents send the Events regarding the object of this uid, as they are created or updated after resourceVersion
+// (as returned by ListEvents(), so that no event is missed or sent twice).
+// The channel is closed when ctx is done, or if the watch ends.
+func (c *client) WatchEvents(ctx context.Context, namespace string, uid string, resourceVersion string) (<-chan Event, error) {
+	res, err := c.resourceInterface("v1", "Event", namespace)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := res.Watch(ctx, metav1.ListOptions{FieldSelector: "involvedObject.uid=" + uid, ResourceVersion: resourceVersion})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch events: %w", err)
+	}
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer watcher.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case we, ok := <-watcher.ResultChan():
+				if !ok {
+					return
+				}
+				obj, isObj := we.Object.(*unstructured.Unstructured)
+				if !isObj || (we.Type != watch.Added && we.Type != watch.Modified) {
+					continue
+				}
+				select {
+				case events <- eventFromObject(obj):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
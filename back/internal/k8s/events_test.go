@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEvents(t *testing.T) {
+	core := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "v1",
+		"kind":           "Event",
+		"metadata":       map[string]interface{}{"name": "e1", "uid": "u1"},
+		"type":           "Warning",
+		"reason":         "InstallFailed",
+		"message":        "chart not found",
+		"source":         map[string]interface{}{"component": "helm-controller"},
+		"count":          int64(3),
+		"firstTimestamp": "2025-06-01T10:00:00Z",
+		"lastTimestamp":  "2025-06-01T12:00:00Z",
+	}}
+	// Same Event, as served by events.k8s.io
+	sameEvent := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "events.k8s.io/v1",
+		"kind":       "Event",
+		"metadata":   map[string]interface{}{"name": "e1", "uid": "u1"},
+		"note":       "chart not found",
+	}}
+	newer := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":          "events.k8s.io/v1",
+		"kind":                "Event",
+		"metadata":            map[string]interface{}{"name": "e2", "uid": "u2"},
+		"type":                "Normal",
+		"reason":              "Created",
+		"note":                "release created",
+		"reportingController": "kubocd",
+		"eventTime":           "2025-06-01T11:00:00.123456Z",
+	}}
+	events := mergeEvents([]Event{eventFromObject(core), eventFromObject(newer), eventFromObject(sameEvent)})
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Uid != "u2" || events[0].Message != "release created" || events[0].Source != "kubocd" || events[0].Count != 1 {
+		t.Errorf("Unexpected first event: %+v", events[0])
+	}
+	if !events[0].LastTime.Equal(time.Date(2025, 6, 1, 11, 0, 0, 123456000, time.UTC)) {
+		t.Errorf("Unexpected event time: %v", events[0].LastTime)
+	}
+	if events[1].Uid != "u1" || events[1].Type != "Warning" || events[1].Count != 3 || events[1].Source != "helm-controller" {
+		t.Errorf("Unexpected second event: %+v", events[1])
+	}
+}
+
+func TestWatchEventsFromList(t *testing.T) {
+	eventsGvr := schema.GroupVersionResource{Version: "v1", Resource: "events"}
+	dynamic := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{eventsGvr: "EventList"})
+	dynamic.PrependReactor("list", "events", func(k8stesting.Action) (bool, runtime.Object, error) {
+		list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "EventList"}}
+		list.SetResourceVersion("42")
+		return true, list, nil
+	})
+	watched := make(chan string, 1)
+	dynamic.PrependWatchReactor("events", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watched <- action.(k8stesting.WatchActionImpl).GetWatchRestrictions().ResourceVersion
+		return true, watch.NewFake(), nil
+	})
+	discovery := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "events", Kind: "Event", Namespaced: true, Verbs: []string{"list", "watch"}}},
+	}}}}
+	c := &client{
+		dynamic:   dynamic,
+		discovery: discovery,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discovery)),
+		logger:    slog.Default(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, resourceVersion, err := c.ListEvents(ctx, "ns1", "u1")
+	if err != nil || resourceVersion != "42" {
+		t.Fatalf("Unexpected list result: %q, %v", resourceVersion, err)
+	}
+	if _, err := c.WatchEvents(ctx, "ns1", "u1", resourceVersion); err != nil {
+		t.Fatalf("WatchEvents() failed: %v", err)
+	}
+	if got := <-watched; got != "42" {
+		t.Errorf("Expected the watch to start from the list version, got %q", got)
+	}
+}
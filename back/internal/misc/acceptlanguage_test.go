@@ -0,0 +1,28 @@
+package misc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected []string
+	}{
+		{"", []string{}},
+		{"fr", []string{"fr"}},
+		{"fr-CA,fr;q=0.9,en;q=0.8", []string{"fr-CA", "fr", "en"}},
+		{"en;q=0.5, de, fr;q=0.7", []string{"de", "fr", "en"}},
+		{"*;q=0.1,es;q=0,it", []string{"it"}},
+		{"en;q=abc,fr", []string{"fr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got := ParseAcceptLanguage(tt.header)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseAcceptLanguage(%q) = %v; want %v", tt.header, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package wrap
+
+import (
+	"krapper/internal/auth"
+	"slices"
+)
+
+// AccessRule list the users and groups allowed to perform an operation
+type AccessRule struct {
+	Users  []string `yaml:"users,omitempty" json:"users,omitempty"`
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// Allows return true if the identity is listed, by login or by one of its groups. A nil rule allows everybody.
+func (r *AccessRule) Allows(identity *auth.Identity) bool {
+	if r == nil {
+		return true
+	}
+	if slices.Contains(r.Users, identity.Login) {
+		return true
+	}
+	for _, group := range identity.Groups {
+		if slices.Contains(r.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Access restrict the operations of a wrap to some users and groups. A missing rule allows everybody.
+type Access struct {
+	View   *AccessRule `yaml:"view,omitempty" json:"view,omitempty"`
+	Create *AccessRule `yaml:"create,omitempty" json:"create,omitempty"`
+	Update *AccessRule `yaml:"update,omitempty" json:"update,omitempty"`
+	Delete *AccessRule `yaml:"delete,omitempty" json:"delete,omitempty"`
+	// Apply to all actions, in addition to the access rule of each action
+	Actions *AccessRule `yaml:"actions,omitempty" json:"actions,omitempty"`
+}
+
+// UserOperations return the operations enabled on the wrap, and allowed to the identity
+func (w *Wrap) UserOperations(identity *auth.Identity) Operations {
+	return Operations{
+		View:   w.Operations.View && w.Access.View.Allows(identity),
+		Create: w.Operations.Create && w.Access.Create.Allows(identity),
+		Update: w.Operations.Update && w.Access.Update.Allows(identity),
+		Delete: w.Operations.Delete && w.Access.Delete.Allows(identity),
+	}
+}
+
+// AllowsAction return true if the identity may perform the action, according to both wrap and action access rules
+func (w *Wrap) AllowsAction(action *Action, identity *auth.Identity) bool {
+	return w.Access.Actions.Allows(identity) && action.Access.Allows(identity)
+}
+
+// Usable return true if the identity is allowed at least one operation or action on the wrap
+func (w *Wrap) Usable(identity *auth.Identity) bool {
+	if w.UserOperations(identity) != (Operations{}) {
+		return true
+	}
+	for idx := range w.Actions {
+		if w.AllowsAction(&w.Actions[idx], identity) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package wrap
+
+import (
+	"krapper/internal/auth"
+	"testing"
+)
+
+func TestAccess(t *testing.T) {
+	w := parseTestWrap(t, `
+operations:
+  view: true
+  create: true
+  update: true
+  delete: false
+access:
+  create:
+    groups: [admins]
+  update:
+    users: [jdoe]
+    groups: [admins]
+  actions:
+    groups: [operators, admins]
+actions:
+  - name: reconcile
+    patch: "{}"
+  - name: purge
+    access:
+      groups: [admins]
+    patch: "{}"
+`)
+	admin := &auth.Identity{Login: "alice", Groups: []string{"admins"}}
+	jdoe := &auth.Identity{Login: "jdoe", Groups: []string{"users"}}
+	operator := &auth.Identity{Login: "bob", Groups: []string{"operators"}}
+
+	if got := w.UserOperations(admin); got != (Operations{View: true, Create: true, Update: true}) {
+		t.Errorf("Unexpected admin operations: %+v", got)
+	}
+	if got := w.UserOperations(jdoe); got != (Operations{View: true, Update: true}) {
+		t.Errorf("Unexpected jdoe operations: %+v", got)
+	}
+	reconcile, purge := w.FindAction("reconcile"), w.FindAction("purge")
+	if w.AllowsAction(reconcile, jdoe) || !w.AllowsAction(reconcile, operator) {
+		t.Errorf("Expected wrap actions rule to apply")
+	}
+	if w.AllowsAction(purge, operator) || !w.AllowsAction(purge, admin) {
+		t.Errorf("Expected both wrap and action rules to apply")
+	}
+
+	// Without view, an operator may still use the wrap for its actions
+	w.Access.View = &AccessRule{} // Nobody
+	if !w.Usable(operator) || w.Usable(&auth.Identity{Login: "eve", Groups: []string{}}) {
+		t.Errorf("Unexpected usable result")
+	}
+}
@@ -0,0 +1,134 @@
+package wrap
+
+import (
+	"bytes"
+	"fmt"
+	"krapper/internal/auth"
+	"krapper/internal/misc"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Action is an operation on an existing object, beyond CRUD (i.e. 'force reconcile', 'suspend' or 'rotate password').
+// It is performed by applying a merge patch, rendered from the Patch template.
+type Action struct {
+	// Required
+	Name string `yaml:"name" json:"name"`
+	// Optional. Default to the labelized name
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+	// Optional. Icon name, interpreted by the front
+	Icon string `yaml:"icon,omitempty" json:"icon,omitempty"`
+	// Optional. If defined, the front must ask the user for confirmation with this text
+	Confirmation string `yaml:"confirmation,omitempty" json:"confirmation,omitempty"`
+	// Optional. Input fields, submitted with the action and provided to the template as .Fields
+	Fields []Field `yaml:"fields,omitempty" json:"fields,omitempty"`
+	// Required. Render a merge patch. Data is an ActionData
+	Patch WrTemplate `yaml:"patch" json:"patch"`
+	// Optional. Users and groups allowed to perform the action. Everybody if not defined
+	Access *AccessRule `yaml:"access,omitempty" json:"access,omitempty"`
+
+	template *template.Template
+}
+
+// ActionData is the data model provided to Action.Patch
+type ActionData struct {
+	Resource map[string]interface{} // The current object
+	Fields   map[string]interface{} // Checked input values, by field name
+	User     *auth.Identity
+	Now      string // RFC3339 timestamp (i.e. for a 'reconcile.fluxcd.io/requestedAt' annotation)
+}
+
+var _ valuePathProvider = &Action{}
+
+func (a *Action) GetValuePath() string {
+	return ""
+}
+
+func (a *Action) groom(wrapName string) error {
+	if a.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if a.Label == "" {
+		a.Label = misc.Labelize(a.Name)
+	}
+	for idx := range a.Fields {
+		field := &a.Fields[idx]
+		err := field.groom(a)
+		if err != nil {
+			return fmt.Errorf("field '%s': %v", field.Name, err)
+		}
+		if field.Type.Reference != nil {
+			return fmt.Errorf("field '%s': reference fields are not supported in actions", field.Name)
+		}
+	}
+	// There is no object set to hold the Secret
+	var stored string
+	visitSecrets(a.Fields, "", func(path string, field *Field) {
+		if field.Type.Secret.Secret != nil && stored == "" {
+			stored = path
+		}
+	})
+	if stored != "" {
+		return fmt.Errorf("field '%s': secret storage is not supported in actions", stored)
+	}
+	if _, err := computeOrder(a.Fields, true); err != nil {
+		return err
+	}
+	if a.Patch == "" {
+		return fmt.Errorf("patch is required")
+	}
+	tmpl, err := parseTemplate(wrapName+"."+a.Name, a.Patch)
+	if err != nil {
+		return fmt.Errorf("invalid patch template: %v", err)
+	}
+	a.template = tmpl
+	return nil
+}
+
+// FindAction return the action of this name, or nil if not found
+func (w *Wrap) FindAction(name string) *Action {
+	for idx := range w.Actions {
+		if w.Actions[idx].Name == name {
+			return &w.Actions[idx]
+		}
+	}
+	return nil
+}
+
+// CheckValues validate the submitted input values of the action, resource being the target object. See Wrap.CheckValues().
+// As there is no previous value to keep, required secrets must be provided, unless inactive.
+func (a *Action) CheckValues(values map[string]interface{}, resource *unstructured.Unstructured) (map[string]interface{}, error) {
+	result, inactive, err := checkValues(a.Fields, nil, values, resource)
+	if err != nil {
+		return nil, err
+	}
+	var errs fieldErrors
+	visitSecrets(a.Fields, "", func(path string, field *Field) {
+		parent, name := valueParent(result, path)
+		if _, ok := parent[name]; !ok && field.Required && !isInactive(inactive, path) {
+			errs.add(path, "%s is required", field.Label)
+		}
+	})
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return result, nil
+}
+
+// Render execute the patch template, and return the patch in json form
+func (a *Action) Render(data *ActionData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := a.template.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error executing patch template of action '%s': %w", a.Name, err)
+	}
+	patch := make(map[string]interface{})
+	if err := yaml.Unmarshal(buf.Bytes(), &patch); err != nil {
+		return nil, fmt.Errorf("patch template of action '%s' does not produce valid yaml: %w", a.Name, err)
+	}
+	if len(patch) == 0 {
+		return nil, fmt.Errorf("patch template of action '%s' produce an empty patch", a.Name)
+	}
+	return yaml.YAMLToJSON(buf.Bytes())
+}
@@ -0,0 +1,113 @@
+package wrap
+
+import (
+	"context"
+	"encoding/json"
+	"krapper/internal/auth"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestActions(t *testing.T) {
+	w := parseTestWrap(t, `
+actions:
+  - name: reconcile
+    confirmation:
+      en: "Reconcile now?"
+      fr: "Réconcilier maintenant ?"
+    patch: |
+      metadata:
+        annotations:
+          reconcile.fluxcd.io/requestedAt: {{ .Now | quote }}
+          requestedBy: {{ .User.Login }}
+  - name: rotatePassword
+    access:
+      groups: [admins]
+    fields:
+      - name: password
+        required: true
+        secret:
+          transform: bcrypt
+          cost: 4
+    patch: |
+      data:
+        hash: {{ .Fields.password | quote }}
+        previous: {{ .Resource.data.hash | quote }}
+`)
+	reconcile := w.FindAction("reconcile")
+	if reconcile == nil || reconcile.Label != "Reconcile" || reconcile.Confirmation != "Reconcile now?" {
+		t.Fatalf("Unexpected action: %+v", reconcile)
+	}
+	if got := w.Localize([]string{"fr"}).FindAction("reconcile").Confirmation; got != "Réconcilier maintenant ?" {
+		t.Errorf("Expected localized confirmation, got %q", got)
+	}
+	if w.FindAction("unknown") != nil {
+		t.Errorf("Expected nil for unknown action")
+	}
+
+	rotate := w.FindAction("rotatePassword")
+	if rotate.Access.Allows(&auth.Identity{Login: "jdoe", Groups: []string{"users"}}) || !rotate.Access.Allows(&auth.Identity{Login: "jdoe", Groups: []string{"admins"}}) {
+		t.Errorf("Unexpected access rule result")
+	}
+	if !reconcile.Access.Allows(&auth.Identity{Login: auth.AnonymousLogin}) {
+		t.Errorf("Expected action without access rule to be allowed")
+	}
+	_, err := rotate.CheckValues(decodeValues(t, `{}`), nil)
+	checkErrorPaths(t, err, "password")
+	values, err := rotate.CheckValues(decodeValues(t, `{"password": "secret"}`), nil)
+	checkErrorPaths(t, err)
+
+	client := &fakeClient{objects: []*unstructured.Unstructured{
+		newTestObject("v1", "ConfigMap", "ns1", "cm1", nil),
+	}}
+	obj := client.objects[0]
+	obj.Object["data"] = map[string]interface{}{"hash": "old", "other": "x"}
+	patch, err := rotate.Render(&ActionData{Resource: obj.Object, Fields: values, User: &auth.Identity{Login: "jdoe"}, Now: "2025-06-01T12:00:00Z"})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	patched, err := client.PatchResource(context.Background(), "v1", "ConfigMap", "ns1", "cm1", patch)
+	if err != nil {
+		t.Fatalf("PatchResource() failed: %v", err)
+	}
+	data := patched.Object["data"].(map[string]interface{})
+	if data["previous"] != "old" || data["other"] != "x" || bcrypt.CompareHashAndPassword([]byte(data["hash"].(string)), []byte("secret")) != nil {
+		t.Errorf("Unexpected patched data: %v", data)
+	}
+
+	patch, err = reconcile.Render(&ActionData{Resource: obj.Object, Fields: map[string]interface{}{}, User: &auth.Identity{Login: "jdoe"}, Now: "2025-06-01T12:00:00Z"})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	var m map[string]interface{}
+	_ = json.Unmarshal(patch, &m)
+	annotations := m["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	if annotations["reconcile.fluxcd.io/requestedAt"] != "2025-06-01T12:00:00Z" || annotations["requestedBy"] != "jdoe" {
+		t.Errorf("Unexpected patch: %s", patch)
+	}
+
+	_, err = Parse([]byte(testWrapHeader+"actions:\n  - name: a\n    patch: x\n  - name: a\n    patch: x\n"), "test")
+	if err == nil {
+		t.Errorf("Expected error on duplicated action")
+	}
+
+	_, err = Parse([]byte(testWrapHeader+`
+actions:
+  - name: rotate
+    fields:
+      - name: credentials
+        object:
+          fields:
+            - name: password
+              secret:
+                secret:
+                  name: "'rotated'"
+    patch: x
+`), "test")
+	if err == nil || !strings.Contains(err.Error(), "field 'credentials.password': secret storage is not supported in actions") {
+		t.Errorf("Expected error on secret storage, got %v", err)
+	}
+}
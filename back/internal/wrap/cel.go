@@ -0,0 +1,103 @@
+package wrap
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gopkg.in/yaml.v3"
+)
+
+// Expressions are only parsed, not type-checked, as variables are dynamic (resource content, field values, ...).
+// Identifiers are resolved against the activation at evaluation time.
+
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		ext.Strings(),
+		ext.Lists(),
+		cel.Function("isYaml",
+			cel.MemberOverload("string_is_yaml", []*cel.Type{cel.StringType}, cel.BoolType,
+				cel.UnaryBinding(func(value ref.Val) ref.Val {
+					var v interface{}
+					return types.Bool(yaml.Unmarshal([]byte(value.(types.String)), &v) == nil)
+				}),
+			),
+		),
+	)
+})
+
+func validCel(exp Cel) error {
+	if exp == "" {
+		return nil
+	}
+	env, err := celEnv()
+	if err != nil {
+		return err
+	}
+	_, issues := env.Parse(string(exp))
+	if issues != nil && issues.Err() != nil {
+		return issues.Err()
+	}
+	return nil
+}
+
+// evalCel evaluate the expression and return the result as a native value
+func evalCel(exp Cel, activation map[string]interface{}) (interface{}, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Parse(string(exp))
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	val, _, err := prg.Eval(activation)
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating '%s': %w", exp, err)
+	}
+	return nativeValue(val.Value()), nil
+}
+
+// nativeValue convert CEL lists, maps, timestamps, durations and null into json like values
+func nativeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []ref.Val:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, nativeValue(item.Value()))
+		}
+		return result
+	case map[ref.Val]ref.Val:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[fmt.Sprintf("%v", k.Value())] = nativeValue(item.Value())
+		}
+		return result
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case time.Duration:
+		return Duration(v).String()
+	case structpb.NullValue:
+		return nil
+	}
+	return value
+}
+
+// resourceActivation expose a k8s object as 'resource', and its top level properties (i.e. '.spec.xxx' or 'metadata.name')
+func resourceActivation(obj map[string]interface{}) map[string]interface{} {
+	activation := make(map[string]interface{}, len(obj)+1)
+	for k, v := range obj {
+		activation[k] = v
+	}
+	activation["resource"] = obj
+	return activation
+}
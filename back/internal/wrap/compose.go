@@ -0,0 +1,300 @@
+package wrap
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fragment is a reusable list of fields, pulled into a fields list by an '- include: <fragmentName>' item.
+type Fragment struct {
+	ApiVersion  string                   `yaml:"apiVersion" json:"apiVersion"`
+	Kind        string                   `yaml:"kind" json:"kind"`
+	Name        string                   `yaml:"name" json:"name"`
+	Description string                   `yaml:"description,omitempty" json:"description,omitempty"`
+	Fields      []map[string]interface{} `yaml:"fields" json:"fields"` // Raw definitions. Groomed as part of the including wrap
+}
+
+func (f *Fragment) Groom() error {
+	if f.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(f.Fields) == 0 {
+		return fmt.Errorf("fragment '%s' has no fields", f.Name)
+	}
+	return nil
+}
+
+// Composite is a wrap using 'extends' (to inherit and override another wrap) or 'include' (to pull fragments).
+// It must be resolved against the other documents to build the effective Wrap.
+type Composite struct {
+	Name     string   `yaml:"name" json:"name"`
+	Extends  string   `yaml:"extends,omitempty" json:"extends,omitempty"`
+	Includes []string `yaml:"includes,omitempty" json:"includes,omitempty"` // Directly included fragments
+}
+
+// DocumentLookup return the active document of a kind ('wrap' or 'fragment') by name, or nil if not found.
+type DocumentLookup func(kind string, name string) *Document
+
+// newComposite return nil if the wrap definition does not use 'extends' or 'include'
+func newComposite(node *yaml.Node) *Composite {
+	root := documentMapping(node)
+	if root == nil {
+		return nil
+	}
+	c := &Composite{
+		Name:     scalarValue(mappingValue(root, "name")),
+		Extends:  scalarValue(mappingValue(root, "extends")),
+		Includes: make([]string, 0),
+	}
+	visitIncludes(root, func(name string) {
+		c.Includes = append(c.Includes, name)
+	})
+	if c.Extends == "" && len(c.Includes) == 0 {
+		return nil
+	}
+	return c
+}
+
+// Resolve build the effective wrap of a Composite document.
+// Also return the wraps and fragments it depends on, directly or not, as 'wrap/<name>' or 'fragment/<name>'
+func (d *Document) Resolve(lookup DocumentLookup) (*Wrap, []string, error) {
+	if d.Composite == nil {
+		return d.Wrap, []string{}, nil
+	}
+	deps := make(map[string]bool)
+	root, err := resolveNode(d, lookup, map[string]bool{"wrap/" + d.Composite.Name: true}, deps)
+	if err != nil {
+		return nil, sortedKeys(deps), fmt.Errorf("wrap '%s': %w", d.Composite.Name, err)
+	}
+	var w Wrap
+	if err := decodeLocalized(root, nil, &w); err != nil {
+		return nil, sortedKeys(deps), fmt.Errorf("error decoding %s: %v", d.location, err)
+	}
+	err = w.Groom()
+	if err != nil {
+		return nil, sortedKeys(deps), err
+	}
+	w.i18n = newLocalized[Wrap](root)
+	return &w, sortedKeys(deps), nil
+}
+
+// resolveNode return a copy of the wrap definition, with base wrap merged and fragments expanded.
+// visiting hold the documents being resolved, to detect cycles.
+func resolveNode(d *Document, lookup DocumentLookup, visiting map[string]bool, deps map[string]bool) (*yaml.Node, error) {
+	root := copyNode(documentMapping(d.node))
+	if extends := scalarValue(mappingValue(root, "extends")); extends != "" {
+		key := "wrap/" + extends
+		if visiting[key] {
+			return nil, fmt.Errorf("extends cycle on wrap '%s'", extends)
+		}
+		deps[key] = true
+		base := lookup("wrap", extends)
+		if base == nil || base.node == nil {
+			return nil, fmt.Errorf("extended wrap '%s' not found", extends)
+		}
+		visiting[key] = true
+		baseRoot, err := resolveNode(base, lookup, visiting, deps)
+		delete(visiting, key)
+		if err != nil {
+			return nil, err
+		}
+		root = mergeWrapNodes(baseRoot, root)
+	}
+	removeMappingKey(root, "extends")
+	err := expandIncludes(root, lookup, visiting, deps)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// mergeWrapNodes override the base definition with the child one. Schema fields are merged by name:
+// a child field replace the base one with the same name, others are appended.
+func mergeWrapNodes(base *yaml.Node, child *yaml.Node) *yaml.Node {
+	for i := 0; i+1 < len(child.Content); i += 2 {
+		key, value := child.Content[i].Value, child.Content[i+1]
+		baseValue := mappingValue(base, key)
+		if key == "schema" && baseValue != nil && baseValue.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				schemaKey, schemaValue := value.Content[j].Value, value.Content[j+1]
+				baseFields := mappingValue(baseValue, schemaKey)
+				if schemaKey == "fields" && baseFields != nil && baseFields.Kind == yaml.SequenceNode && schemaValue.Kind == yaml.SequenceNode {
+					mergeFieldNodes(baseFields, schemaValue)
+				} else {
+					setMappingValue(baseValue, schemaKey, schemaValue)
+				}
+			}
+			continue
+		}
+		setMappingValue(base, key, value)
+	}
+	return base
+}
+
+func mergeFieldNodes(base *yaml.Node, child *yaml.Node) {
+	byName := make(map[string]int)
+	for idx, item := range base.Content {
+		if name := scalarValue(mappingValue(item, "name")); name != "" {
+			byName[name] = idx
+		}
+	}
+	for _, item := range child.Content {
+		name := scalarValue(mappingValue(item, "name"))
+		if idx, ok := byName[name]; ok && name != "" {
+			base.Content[idx] = item
+		} else {
+			base.Content = append(base.Content, item)
+		}
+	}
+}
+
+// expandIncludes replace, in all fields lists, the '- include: <fragmentName>' items by the fragment fields
+func expandIncludes(node *yaml.Node, lookup DocumentLookup, visiting map[string]bool, deps map[string]bool) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if node.Content[i].Value == "fields" && value.Kind == yaml.SequenceNode {
+				items := make([]*yaml.Node, 0, len(value.Content))
+				for _, item := range value.Content {
+					name := scalarValue(mappingValue(item, "include"))
+					if name == "" {
+						if err := expandIncludes(item, lookup, visiting, deps); err != nil {
+							return err
+						}
+						items = append(items, item)
+						continue
+					}
+					fields, err := fragmentFields(name, lookup, visiting, deps)
+					if err != nil {
+						return err
+					}
+					items = append(items, fields...)
+				}
+				value.Content = items
+				continue
+			}
+			if err := expandIncludes(value, lookup, visiting, deps); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := expandIncludes(item, lookup, visiting, deps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fragmentFields return a copy of the fields of a fragment, with nested includes expanded
+func fragmentFields(name string, lookup DocumentLookup, visiting map[string]bool, deps map[string]bool) ([]*yaml.Node, error) {
+	key := "fragment/" + name
+	if visiting[key] {
+		return nil, fmt.Errorf("include cycle on fragment '%s'", name)
+	}
+	deps[key] = true
+	fragment := lookup("fragment", name)
+	if fragment == nil || fragment.node == nil {
+		return nil, fmt.Errorf("included fragment '%s' not found", name)
+	}
+	root := copyNode(documentMapping(fragment.node))
+	visiting[key] = true
+	defer delete(visiting, key)
+	if err := expandIncludes(root, lookup, visiting, deps); err != nil {
+		return nil, err
+	}
+	fields := mappingValue(root, "fields")
+	if fields == nil || fields.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("fragment '%s' has no fields", name)
+	}
+	return fields.Content, nil
+}
+
+// visitIncludes call fn with the fragment name of each include item
+func visitIncludes(node *yaml.Node, fn func(name string)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if node.Content[i].Value == "fields" && value.Kind == yaml.SequenceNode {
+				for _, item := range value.Content {
+					if name := scalarValue(mappingValue(item, "include")); name != "" {
+						fn(name)
+					}
+				}
+			}
+			visitIncludes(value, fn)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			visitIncludes(item, fn)
+		}
+	}
+}
+
+// ------------------------------------------------------------ yaml.Node helpers
+
+func documentMapping(node *yaml.Node) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	return node
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+func removeMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	c := *node
+	if node.Content != nil {
+		c.Content = make([]*yaml.Node, 0, len(node.Content))
+		for _, child := range node.Content {
+			c.Content = append(c.Content, copyNode(child))
+		}
+	}
+	return &c
+}
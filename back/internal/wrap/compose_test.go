@@ -0,0 +1,32 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompositeCycles(t *testing.T) {
+	parse := func(body string) *Document {
+		doc, err := ParseDocument([]byte(body), "test")
+		if err != nil || doc == nil {
+			t.Fatalf("ParseDocument() failed: %v", err)
+		}
+		return doc
+	}
+	docs := map[string]*Document{
+		"wrap/a":     parse(strings.Replace(testWrapHeader, "name: test", "name: a", 1) + "extends: b\n"),
+		"fragment/f": parse("apiVersion: krapper.kubotal.io/v1alpha1\nkind: Fragment\nname: f\nfields:\n  - include: g\n"),
+		"fragment/g": parse("apiVersion: krapper.kubotal.io/v1alpha1\nkind: Fragment\nname: g\nfields:\n  - include: f\n"),
+	}
+	docs["wrap/b"] = parse(strings.Replace(testWrapHeader, "name: test", "name: b", 1) + "extends: a\n")
+	docs["wrap/c"] = parse(strings.Replace(testWrapHeader, "name: test", "name: c", 1) + "schema:\n  fields:\n    - include: f\n")
+	lookup := func(kind string, name string) *Document {
+		return docs[kind+"/"+name]
+	}
+	for name, expected := range map[string]string{"b": "extends cycle", "c": "include cycle"} {
+		_, _, err := docs["wrap/"+name].Resolve(lookup)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Wrap %s: expected '%s' error, got %v", name, expected, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package wrap
+
+import (
+	"fmt"
+
+	celast "github.com/google/cel-go/common/ast"
+)
+
+// celIdentifiers return the identifiers referenced by an expression, including 'fields.xxx' selections as 'xxx' if root is true.
+func celIdentifiers(exp Cel, root bool) (map[string]bool, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Parse(string(exp))
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	identifiers := make(map[string]bool)
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(e celast.Expr) {
+		switch e.Kind() {
+		case celast.IdentKind:
+			identifiers[e.AsIdent()] = true
+		case celast.SelectKind:
+			sel := e.AsSelect()
+			if root && sel.Operand().Kind() == celast.IdentKind && sel.Operand().AsIdent() == "fields" {
+				identifiers[sel.FieldName()] = true
+			}
+		}
+	}))
+	return identifiers, nil
+}
+
+// computeOrder return the indexes of the computed fields, ordered so that each one is evaluated after the
+// computed fields it depends on. root is true for the top level fields, which may also be referenced as 'fields.xxx'.
+func computeOrder(fields []Field, root bool) ([]int, error) {
+	byName := make(map[string]int)
+	for idx := range fields {
+		if fields[idx].Compute != "" {
+			byName[fields[idx].Name] = idx
+		}
+	}
+	dependencies := make(map[int][]int, len(byName))
+	for _, idx := range byName {
+		identifiers, err := celIdentifiers(fields[idx].Compute, root)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': invalid compute expression: %w", fields[idx].Name, err)
+		}
+		for name := range identifiers {
+			if dep, ok := byName[name]; ok {
+				dependencies[idx] = append(dependencies[idx], dep)
+			}
+		}
+	}
+	order := make([]int, 0, len(byName))
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[int]int, len(byName))
+	var visit func(idx int) error
+	visit = func(idx int) error {
+		switch state[idx] {
+		case visiting:
+			return fmt.Errorf("field '%s': compute expressions form a cycle", fields[idx].Name)
+		case done:
+			return nil
+		}
+		state[idx] = visiting
+		for _, dep := range dependencies[idx] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[idx] = done
+		order = append(order, idx)
+		return nil
+	}
+	// Iterate in declaration order, for a stable result
+	for idx := range fields {
+		if fields[idx].Compute == "" {
+			continue
+		}
+		if err := visit(idx); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// stripComputed remove the submitted values of computed fields, including nested ones, so that expressions don't see them
+func stripComputed(fields []Field, values map[string]interface{}) {
+	for idx := range fields {
+		field := &fields[idx]
+		if field.Compute != "" {
+			delete(values, field.Name)
+			continue
+		}
+		switch {
+		case field.Type.Object != nil:
+			if m, ok := values[field.Name].(map[string]interface{}); ok {
+				stripComputed(field.Type.Object.Fields, m)
+			}
+		case field.Type.Array != nil && field.Type.Array.Item.Type.Object != nil:
+			if items, ok := values[field.Name].([]interface{}); ok {
+				for _, item := range items {
+					if m, ok := item.(map[string]interface{}); ok {
+						stripComputed(field.Type.Array.Item.Type.Object.Fields, m)
+					}
+				}
+			}
+		}
+	}
+}
+
+// computeValues evaluate the computed fields of the checked values, then the ones of nested objects,
+// so that nested expressions may reference root computed fields.
+func computeValues(fields []Field, values map[string]interface{}, path string, vc *valueChecker) {
+	computeFields(fields, values, path, vc)
+	for idx := range fields {
+		field := &fields[idx]
+		fieldPath := joinFieldPath(path, field.Name)
+		switch {
+		case field.Type.Object != nil:
+			if m, ok := values[field.Name].(map[string]interface{}); ok {
+				computeValues(field.Type.Object.Fields, m, fieldPath, vc)
+			}
+		case field.Type.Array != nil && field.Type.Array.Item.Type.Object != nil:
+			if items, ok := values[field.Name].([]interface{}); ok {
+				for i, item := range items {
+					if m, ok := item.(map[string]interface{}); ok {
+						computeValues(field.Type.Array.Item.Type.Object.Fields, m, fmt.Sprintf("%s[%d]", fieldPath, i), vc)
+					}
+				}
+			}
+		}
+	}
+}
+
+// computeFields evaluate the computed fields against the checked values, and add the results to them.
+// An expression which can't be evaluated (i.e. referencing a missing value) is reported. A null result produces no value.
+func computeFields(fields []Field, result map[string]interface{}, path string, vc *valueChecker) {
+	order, _ := computeOrder(fields, path == "") // Errors are reported by Groom()
+	for _, idx := range order {
+		field := &fields[idx]
+		fieldPath := joinFieldPath(path, field.Name)
+		if !vc.isActive(fieldPath, field, vc.activation(fields, result, nil)) {
+			continue
+		}
+		value, err := evalCel(field.Compute, vc.activation(fields, result, nil))
+		if err != nil {
+			vc.add(fieldPath, "unable to compute value: %v", err)
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		result[field.Name] = field.Type.check(fieldPath, value, vc)
+		if path == "" {
+			vc.root[field.Name] = celValue(result[field.Name])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package wrap
+
+import (
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: label
+      compute: "displayName + ' (' + login + ')'"
+    - name: displayName
+      compute: "firstName + ' ' + lastName"
+    - name: login
+    - name: firstName
+    - name: lastName
+    - name: size
+      integer: {}
+      compute: "size(fields.login)"
+    - name: profile
+      object:
+        fields:
+          - name: home
+            compute: "'/home/' + fields.login"
+          - name: title
+            compute: "fields.displayName"
+    - name: nickname
+      condition: "!has(fields.label)"
+    - name: initial
+      compute: "firstName == null ? null : firstName.substring(0, 1)"
+`)
+	if w.Schema.Fields[0].ReadOnly != "true" {
+		t.Errorf("Computed field should be read only")
+	}
+	// Submitted values of computed fields are not visible to expressions
+	values, _, err := w.CheckValues(decodeValues(t, `{"login": "jdoe", "firstName": "John", "lastName": "Doe", "label": "ignored", "nickname": "JD", "profile": {"home": "ignored"}}`), nil)
+	checkErrorPaths(t, err)
+	if values["label"] != "John Doe (jdoe)" || values["displayName"] != "John Doe" || values["size"] != 4 || values["nickname"] != "JD" || values["initial"] != "J" {
+		t.Errorf("Unexpected values: %v", values)
+	}
+	// Nested fields are computed after the root ones
+	profile := values["profile"].(map[string]interface{})
+	if profile["home"] != "/home/jdoe" || profile["title"] != "John Doe" {
+		t.Errorf("Unexpected nested values: %v", profile)
+	}
+
+	// A null result produce no value
+	values, _, err = w.CheckValues(decodeValues(t, `{"login": "jdoe", "firstName": null, "lastName": "Doe"}`), nil)
+	checkErrorPaths(t, err, "displayName", "label")
+	// An expression which can't be evaluated is reported
+	_, _, err = w.CheckValues(decodeValues(t, `{"login": "jdoe", "firstName": "John"}`), nil)
+	checkErrorPaths(t, err, "displayName", "label")
+	values, _, err = w.CheckValues(decodeValues(t, `{"login": "jdoe"}`), nil)
+	checkErrorPaths(t, err, "displayName", "label")
+
+	_, err = Parse([]byte(testWrapHeader+`
+schema:
+  fields:
+    - name: a
+      compute: "b + '.'"
+    - name: b
+      compute: "fields.c + '.'"
+    - name: c
+      compute: "a"
+`), "test")
+	if err == nil {
+		t.Error("Expected an error on compute cycle")
+	}
+}
@@ -0,0 +1,108 @@
+package wrap
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestConditionAndValidation(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  validation:
+    test: "!(has(self.mode) && self.mode == 'advanced') || self.options.retries > 0"
+    message: "Advanced mode requires retries"
+  fields:
+    - name: mode
+      string:
+        enum: [simple, advanced]
+    - name: options
+      condition: "mode == 'advanced'"
+      object:
+        fields:
+          - name: retries
+            required: true
+            integer: {}
+          - name: proxy
+            condition: "fields.mode == 'advanced' && retries != null && retries > 2"
+            validation:
+              test: "self.startsWith('http')"
+              message: "Must be an url"
+    - name: emails
+      array:
+        item:
+          validation:
+            test: "self.contains('@')"
+            message: "Invalid email"
+          string: {}
+`)
+	// Inactive fields values are discarded, and their rules skipped
+	values, _, err := w.CheckValues(decodeValues(t, `{"mode": "simple", "options": {"proxy": "bad"}}`), nil)
+	checkErrorPaths(t, err)
+	if _, ok := values["options"]; ok {
+		t.Errorf("Inactive field not discarded: %v", values)
+	}
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"mode": "advanced", "options": {"retries": 3, "proxy": "bad"}, "emails": ["a@b", "c"]}`), nil)
+	checkErrorPaths(t, err, "options.proxy", "emails[1]")
+
+	values, _, err = w.CheckValues(decodeValues(t, `{"mode": "advanced", "options": {"retries": 1, "proxy": "bad"}}`), nil)
+	checkErrorPaths(t, err)
+	if _, ok := values["options"].(map[string]interface{})["proxy"]; ok {
+		t.Errorf("Inactive nested field not discarded: %v", values)
+	}
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"mode": "advanced", "options": {}}`), nil)
+	checkErrorPaths(t, err, "options.retries")
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"mode": "advanced", "options": {"retries": 0}}`), nil)
+	checkErrorPaths(t, err, "")
+}
+
+func TestConditionContext(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: mode
+      condition: "resource == null"
+    - name: level
+      condition: "mode == 'advanced' || (has(fields.mode) && fields.mode == 'expert')"
+      integer: {}
+    - name: check
+      validation:
+        test: "!has(fields.level)"
+        message: "level must be discarded"
+`)
+	existing := &unstructured.Unstructured{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "x"}}}
+	// The mode can only be set on creation. Once discarded, it doesn't activate other fields
+	values, _, err := w.CheckValues(decodeValues(t, `{"mode": "advanced", "check": "x"}`), existing)
+	checkErrorPaths(t, err)
+	if _, ok := values["mode"]; ok {
+		t.Errorf("Inactive field not discarded: %v", values)
+	}
+	values, _, err = w.CheckValues(decodeValues(t, `{"mode": "advanced", "level": 1}`), nil)
+	checkErrorPaths(t, err)
+	if values["mode"] != "advanced" || values["level"] != 1 {
+		t.Errorf("Unexpected values: %v", values)
+	}
+	// Inactive values are not visible to validations
+	_, _, err = w.CheckValues(decodeValues(t, `{"mode": "simple", "level": 3, "check": "x"}`), nil)
+	checkErrorPaths(t, err)
+
+	// A condition which can't be evaluated (level is null) makes the field inactive, without error
+	w = parseTestWrap(t, `
+schema:
+  fields:
+    - name: level
+      integer: {}
+    - name: retries
+      required: true
+      condition: "level > 2"
+      integer: {}
+`)
+	values, _, err = w.CheckValues(decodeValues(t, `{"retries": 2}`), nil)
+	checkErrorPaths(t, err)
+	if _, ok := values["retries"]; ok {
+		t.Errorf("Field with failing condition not discarded: %v", values)
+	}
+}
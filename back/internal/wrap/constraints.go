@@ -0,0 +1,41 @@
+package wrap
+
+import (
+	"fmt"
+	"math"
+)
+
+// groomBounds check the consistency of min/max/step constraints
+func groomBounds[T int | float64](min *T, max *T, step T) error {
+	if min != nil && max != nil && *max < *min {
+		return fmt.Errorf("max '%v' is lower than min '%v'", *max, *min)
+	}
+	if step < 0 {
+		return fmt.Errorf("step must be positive")
+	}
+	return nil
+}
+
+// checkBounds return an error message, or "" if value is within [min, max] and is a multiple of step (Counted from min if defined)
+func checkBounds[T int | float64](value T, min *T, max *T, step T) string {
+	if min != nil && value < *min {
+		return fmt.Sprintf("must be at least %v", *min)
+	}
+	if max != nil && value > *max {
+		return fmt.Sprintf("must be at most %v", *max)
+	}
+	if step != 0 {
+		base := T(0)
+		if min != nil {
+			base = *min
+		}
+		steps := float64(value-base) / float64(step)
+		if math.Abs(steps-math.Round(steps)) > 1e-9 {
+			if base != 0 {
+				return fmt.Sprintf("must be %v plus a multiple of %v", base, step)
+			}
+			return fmt.Sprintf("must be a multiple of %v", step)
+		}
+	}
+	return ""
+}
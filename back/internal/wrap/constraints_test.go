@@ -0,0 +1,68 @@
+package wrap
+
+import (
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: login
+      string:
+        minLength: 3
+        maxLength: 8
+        pattern: "^[a-z]+$"
+    - name: replicas
+      integer:
+        min: 1
+        max: 9
+        step: 2
+    - name: ratio
+      number:
+        min: 0
+        max: 1
+        step: 0.25
+    - name: tags
+      array:
+        minItems: 1
+        maxItems: 3
+        uniqueItems: true
+        item:
+          string: {}
+`)
+	_, _, err := w.CheckValues(decodeValues(t, `{"login": "abc", "replicas": 3, "ratio": 0.75, "tags": ["a", "b"]}`), nil)
+	checkErrorPaths(t, err)
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"login": "ab", "replicas": 11, "ratio": -0.5, "tags": []}`), nil)
+	checkErrorPaths(t, err, "login", "replicas", "ratio", "tags")
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"login": "Abcd", "replicas": 4, "ratio": 0.3, "tags": ["a", "b", "a"]}`), nil)
+	checkErrorPaths(t, err, "login", "replicas", "ratio", "tags[2]")
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"login": "abcdefghij", "tags": ["a", "b", "c", "d"]}`), nil)
+	checkErrorPaths(t, err, "login", "tags")
+}
+
+func TestConstraintsGroom(t *testing.T) {
+	for _, schema := range []string{
+		"string: {minLength: 4, maxLength: 2}",
+		"string: {pattern: '^[a-z]+$', default: 'ABC'}",
+		"string: {pattern: '(['}",
+		"integer: {min: 2, max: 1}",
+		"integer: {min: 2, default: 1}",
+		"integer: {step: 5, default: 7}",
+		"number: {max: 1.5, default: 2}",
+		"array: {minItems: 3, maxItems: 1, item: {string: {}}}",
+	} {
+		_, err := Parse([]byte(testWrapHeader+`
+schema:
+  fields:
+    - name: field
+      `+schema+`
+`), "test")
+		if err == nil {
+			t.Errorf("Expected an error for '%s'", schema)
+		}
+	}
+}
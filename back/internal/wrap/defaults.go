@@ -0,0 +1,103 @@
+package wrap
+
+import (
+	"fmt"
+	"krapper/internal/auth"
+	"time"
+)
+
+// staticDefault return the Default of the type, if defined
+func (t *Type) staticDefault() (interface{}, bool) {
+	switch {
+	case t.Boolean != nil && t.Boolean.Default != nil:
+		return *t.Boolean.Default, true
+	case t.DateTime != nil:
+		return t.DateTime.Default, t.DateTime.Default != ""
+	case t.Duration != nil:
+		return t.Duration.Default.String(), t.Duration.Default != 0
+	case t.Integer != nil && t.Integer.Default != nil:
+		return *t.Integer.Default, true
+	case t.Map != nil:
+		return t.Map.Default, len(t.Map.Default) > 0
+	case t.Number != nil && t.Number.Default != nil:
+		return *t.Number.Default, true
+	case t.Quantity != nil:
+		return t.Quantity.Default, t.Quantity.Default != ""
+	case t.String != nil:
+		return t.String.Default, t.String.Default != ""
+	}
+	return nil, false
+}
+
+// Defaults resolve the default values of a create form, by field name (nested for objects).
+// defaultExpr expressions are evaluated in declaration order, with access to 'user' (login and groups), 'now',
+// 'targetNamespace' (If known. 'namespace' is a CEL reserved word) and 'defaults' (the ones already resolved).
+// Arrays are empty at creation, so the defaults of their items are not resolved here: only a defaultExpr on the
+// array field itself may provide items.
+func (w *Wrap) Defaults(user *auth.Identity, namespace string, now time.Time) (map[string]interface{}, error) {
+	defaults := staticDefaults(w.Schema.Fields)
+	activation := map[string]interface{}{
+		"user":            map[string]interface{}{"login": user.Login, "groups": user.Groups},
+		"now":             now,
+		"targetNamespace": namespace,
+		"defaults":        defaults,
+	}
+	vc := &valueChecker{root: defaults}
+	err := evalDefaults(w.Schema.Fields, defaults, "", activation, vc)
+	if err != nil {
+		return nil, err
+	}
+	if len(vc.errs) > 0 {
+		return nil, fmt.Errorf("invalid default values: %w", &ValidationError{Errors: vc.errs})
+	}
+	return defaults, nil
+}
+
+func staticDefaults(fields []Field) map[string]interface{} {
+	defaults := make(map[string]interface{})
+	for idx := range fields {
+		field := &fields[idx]
+		if field.Type.Object != nil {
+			if nested := staticDefaults(field.Type.Object.Fields); len(nested) > 0 {
+				defaults[field.Name] = nested
+			}
+			continue
+		}
+		if value, ok := field.Type.staticDefault(); ok {
+			defaults[field.Name] = value
+		}
+	}
+	return defaults
+}
+
+func evalDefaults(fields []Field, defaults map[string]interface{}, path string, activation map[string]interface{}, vc *valueChecker) error {
+	for idx := range fields {
+		field := &fields[idx]
+		fieldPath := joinFieldPath(path, field.Name)
+		if field.Type.Object != nil {
+			nested, ok := defaults[field.Name].(map[string]interface{})
+			if !ok {
+				nested = make(map[string]interface{})
+			}
+			err := evalDefaults(field.Type.Object.Fields, nested, fieldPath, activation, vc)
+			if err != nil {
+				return err
+			}
+			if len(nested) > 0 {
+				defaults[field.Name] = nested
+			}
+			continue
+		}
+		if field.DefaultExpr == "" {
+			continue
+		}
+		value, err := evalCel(field.DefaultExpr, activation)
+		if err != nil {
+			return fmt.Errorf("field '%s': defaultExpr: %w", fieldPath, err)
+		}
+		if value != nil {
+			defaults[field.Name] = field.Type.check(fieldPath, value, vc)
+		}
+	}
+	return nil
+}
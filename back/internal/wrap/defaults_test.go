@@ -0,0 +1,83 @@
+package wrap
+
+import (
+	"fmt"
+	"krapper/internal/auth"
+	"testing"
+	"time"
+)
+
+func TestDefaults(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: owner
+      defaultExpr: "user.login"
+    - name: name
+      defaultExpr: "defaults.owner + '-' + targetNamespace"
+    - name: admin
+      boolean: {}
+      defaultExpr: "'admins' in user.groups"
+    - name: expiry
+      dateTime: {}
+      defaultExpr: "now + duration('24h')"
+    - name: retries
+      integer:
+        default: 3
+    - name: options
+      object:
+        fields:
+          - name: timeout
+            duration:
+              default: 5m
+          - name: comment
+            defaultExpr: "'Created by ' + user.login"
+    - name: enabled
+      boolean: {}
+    - name: notify
+      boolean:
+        default: false
+    - name: ports
+      array:
+        item:
+          object:
+            fields:
+              - name: protocol
+                string:
+                  default: TCP
+    - name: tags
+      defaultExpr: "[user.login]"
+      array:
+        item:
+          string: {}
+`)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	defaults, err := w.Defaults(&auth.Identity{Login: "jdoe", Groups: []string{"admins"}}, "ns1", now)
+	if err != nil {
+		t.Fatalf("Defaults() failed: %v", err)
+	}
+	expected := "map[admin:true expiry:2025-06-02T12:00:00Z name:jdoe-ns1 notify:false options:map[comment:Created by jdoe timeout:5m] owner:jdoe retries:3 tags:[jdoe]]"
+	if fmt.Sprint(defaults) != expected {
+		t.Errorf("Expected %s, got %v", expected, defaults)
+	}
+
+	defaults, err = w.Defaults(auth.FromContext(t.Context()), "", now)
+	if err != nil {
+		t.Fatalf("Defaults() failed: %v", err)
+	}
+	if defaults["owner"] != auth.AnonymousLogin || defaults["admin"] != false {
+		t.Errorf("Unexpected anonymous defaults: %v", defaults)
+	}
+
+	_, err = Parse([]byte(testWrapHeader+`
+schema:
+  fields:
+    - name: owner
+      defaultExpr: "user.login"
+      string:
+        default: "admin"
+`), "test")
+	if err == nil {
+		t.Error("Expected an error with both default and defaultExpr")
+	}
+}
@@ -0,0 +1,69 @@
+package wrap
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnumItem is an allowed value, with an optional label.
+// In the wrap file, it can be provided as a plain value, or as a 'value/label' map.
+type EnumItem[T comparable] struct {
+	Value T      `yaml:"value" json:"value"`
+	Label string `yaml:"label,omitempty" json:"label,omitempty"` // Default to value
+}
+
+func (e *EnumItem[T]) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.Value)
+	}
+	var item struct {
+		Value *T     `yaml:"value"`
+		Label string `yaml:"label"`
+	}
+	if err := node.Decode(&item); err != nil {
+		return err
+	}
+	if item.Value == nil {
+		return fmt.Errorf("line %d: enum entry must have a value", node.Line)
+	}
+	e.Value = *item.Value
+	e.Label = item.Label
+	return nil
+}
+
+func groomEnum[T comparable](enum []EnumItem[T]) error {
+	seen := make(map[T]bool, len(enum))
+	for idx := range enum {
+		if seen[enum[idx].Value] {
+			return fmt.Errorf("duplicated enum value: %v", enum[idx].Value)
+		}
+		seen[enum[idx].Value] = true
+		if enum[idx].Label == "" {
+			enum[idx].Label = fmt.Sprintf("%v", enum[idx].Value)
+		}
+	}
+	return nil
+}
+
+func enumContains[T comparable](enum []EnumItem[T], value T) bool {
+	for idx := range enum {
+		if enum[idx].Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Ui components which require an enum
+var enumUiComponents = map[UiComponent]bool{
+	"select": true,
+	"radio":  true,
+}
+
+func validEnumUiComponent(component UiComponent, enumSize int) error {
+	if enumUiComponents[component] && enumSize == 0 {
+		return fmt.Errorf("UiComponent '%s' requires an enum", component)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package wrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"krapper/internal/k8s"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeClient serve a set of objects, updated by apply and delete
+type fakeClient struct {
+	objects   []*unstructured.Unstructured
+	failApply string // Kind whose apply fails
+}
+
+func (c *fakeClient) ListResources(_ context.Context, apiVersion, kind, namespace string, selector map[string]string) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, obj := range c.objects {
+		if obj.GetAPIVersion() == apiVersion && obj.GetKind() == kind && (namespace == "" || obj.GetNamespace() == namespace) && matchLabels(obj, selector) {
+			list.Items = append(list.Items, *obj.DeepCopy())
+		}
+	}
+	return list, nil
+}
+
+func matchLabels(obj *unstructured.Unstructured, selector map[string]string) bool {
+	for k, v := range selector {
+		if obj.GetLabels()[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *fakeClient) GetResource(_ context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	for _, obj := range c.objects {
+		if obj.GetAPIVersion() == apiVersion && obj.GetKind() == kind && obj.GetNamespace() == namespace && obj.GetName() == name {
+			return obj, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Resource: kind}, namespace+"/"+name)
+}
+
+func (c *fakeClient) ApplyResource(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if obj.GetKind() == c.failApply {
+		return nil, fmt.Errorf("failed to apply %s '%s'", obj.GetKind(), obj.GetName())
+	}
+	_ = c.DeleteResource(ctx, obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	c.objects = append(c.objects, obj.DeepCopy())
+	return obj, nil
+}
+
+func (c *fakeClient) PatchResource(ctx context.Context, apiVersion, kind, namespace, name string, patch []byte) (*unstructured.Unstructured, error) {
+	obj, err := c.GetResource(ctx, apiVersion, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	var changes map[string]interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+	mergePatch(obj.Object, changes)
+	return obj, nil
+}
+
+// mergePatch is a minimal json merge patch (RFC 7386) implementation
+func mergePatch(target map[string]interface{}, patch map[string]interface{}) {
+	for k, v := range patch {
+		switch value := v.(type) {
+		case nil:
+			delete(target, k)
+		case map[string]interface{}:
+			child, ok := target[k].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				target[k] = child
+			}
+			mergePatch(child, value)
+		default:
+			target[k] = value
+		}
+	}
+}
+
+func (c *fakeClient) ListEvents(context.Context, string, string) ([]k8s.Event, string, error) {
+	return []k8s.Event{}, "", nil
+}
+
+func (c *fakeClient) WatchEvents(context.Context, string, string, string) (<-chan k8s.Event, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (c *fakeClient) DeleteResource(_ context.Context, apiVersion, kind, namespace, name string) error {
+	for idx, obj := range c.objects {
+		if obj.GetAPIVersion() == apiVersion && obj.GetKind() == kind && obj.GetNamespace() == namespace && obj.GetName() == name {
+			c.objects = append(c.objects[:idx], c.objects[idx+1:]...)
+			return nil
+		}
+	}
+	return apierrors.NewNotFound(schema.GroupResource{Resource: kind}, namespace+"/"+name)
+}
+
+func newTestObject(apiVersion, kind, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
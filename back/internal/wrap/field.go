@@ -13,8 +13,14 @@ type Field struct {
 	Validation *Validation `yaml:"validation,omitempty" json:"validation,omitempty"`
 	Required   bool        `yaml:"required" json:"required"`
 
+	// If false (or failing to evaluate), the field is hidden, and its submitted value discarded. Sibling fields are available by name, root ones under 'fields',
+	// and the existing object as 'resource' (null on creation)
 	Condition Cel `yaml:"condition,omitempty" json:"condition,omitempty"`
 	ReadOnly  Cel `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+	// If defined, evaluated to prefill create forms. See Wrap.Defaults()
+	DefaultExpr Cel `yaml:"defaultExpr,omitempty" json:"defaultExpr,omitempty"`
+	// If defined, the value is computed on submit from the other fields (Same variables as Condition). Such field is read only.
+	Compute Cel `yaml:"compute,omitempty" json:"compute,omitempty"`
 
 	Type Type `yaml:",inline" json:",inline"`
 
@@ -49,23 +55,50 @@ func (f *Field) groom(pathProvider valuePathProvider) error {
 	if err != nil {
 		return fmt.Errorf("invalid readOnly expression: %w", err)
 	}
+	if f.DefaultExpr != "" {
+		err = validCel(f.DefaultExpr)
+		if err != nil {
+			return fmt.Errorf("invalid defaultExpr: %w", err)
+		}
+	}
+	if f.Compute != "" {
+		err = validCel(f.Compute)
+		if err != nil {
+			return fmt.Errorf("invalid compute expression: %w", err)
+		}
+		if f.Required {
+			return fmt.Errorf("a computed field can't be required")
+		}
+		f.ReadOnly = "true"
+	}
 	defaultValueCel := Cel(joinPath(pathProvider.GetValuePath(), f.Name))
 
 	err = f.Type.groom(defaultValueCel, f.Label)
 	if err != nil {
 		return err
 	}
+	if _, ok := f.Type.staticDefault(); ok && f.DefaultExpr != "" {
+		return fmt.Errorf("can't define both a default and a defaultExpr")
+	}
+	if f.DefaultExpr != "" && (f.Type.Object != nil || f.Type.Secret != nil) {
+		return fmt.Errorf("defaultExpr is not supported on object and secret fields")
+	}
 	return nil
 }
 
 type Type struct {
-	Array    *FieldArray    `yaml:"array,omitempty" json:"array,omitempty"`
-	Boolean  *FieldBoolean  `yaml:"boolean,omitempty" json:"boolean,omitempty"`
-	Duration *FieldDuration `yaml:"duration,omitempty" json:"duration,omitempty"`
-	Integer  *FieldInteger  `yaml:"integer,omitempty" json:"integer,omitempty"`
-	Number   *FieldNumber   `yaml:"number,omitempty" json:"number,omitempty"`
-	Object   *FieldObject   `yaml:"object,omitempty" json:"object,omitempty"`
-	String   *FieldString   `yaml:"string,omitempty" json:"string,omitempty"`
+	Array     *FieldArray     `yaml:"array,omitempty" json:"array,omitempty"`
+	Boolean   *FieldBoolean   `yaml:"boolean,omitempty" json:"boolean,omitempty"`
+	DateTime  *FieldDateTime  `yaml:"dateTime,omitempty" json:"dateTime,omitempty"`
+	Duration  *FieldDuration  `yaml:"duration,omitempty" json:"duration,omitempty"`
+	Integer   *FieldInteger   `yaml:"integer,omitempty" json:"integer,omitempty"`
+	Map       *FieldMap       `yaml:"map,omitempty" json:"map,omitempty"`
+	Number    *FieldNumber    `yaml:"number,omitempty" json:"number,omitempty"`
+	Object    *FieldObject    `yaml:"object,omitempty" json:"object,omitempty"`
+	Quantity  *FieldQuantity  `yaml:"quantity,omitempty" json:"quantity,omitempty"`
+	Reference *FieldReference `yaml:"reference,omitempty" json:"reference,omitempty"`
+	Secret    *FieldSecret    `yaml:"secret,omitempty" json:"secret,omitempty"`
+	String    *FieldString    `yaml:"string,omitempty" json:"string,omitempty"`
 }
 
 func (t *Type) groom(defaultValueCel Cel, label string) error {
@@ -90,6 +123,16 @@ func (t *Type) groom(defaultValueCel Cel, label string) error {
 			return err
 		}
 	}
+	if t.DateTime != nil {
+		if myType != "" {
+			return fmt.Errorf("can't be '%s' and 'dateTime'", myType)
+		}
+		myType = "dateTime"
+		err := t.DateTime.groom(defaultValueCel, label)
+		if err != nil {
+			return err
+		}
+	}
 	if t.Duration != nil {
 		if myType != "" {
 			return fmt.Errorf("can't be '%s' and 'duration'", myType)
@@ -110,6 +153,16 @@ func (t *Type) groom(defaultValueCel Cel, label string) error {
 			return err
 		}
 	}
+	if t.Map != nil {
+		if myType != "" {
+			return fmt.Errorf("can't be '%s' and 'map'", myType)
+		}
+		myType = "map"
+		err := t.Map.groom(defaultValueCel, label)
+		if err != nil {
+			return err
+		}
+	}
 	if t.Number != nil {
 		if myType != "" {
 			return fmt.Errorf("can't be '%s' and 'number'", myType)
@@ -130,6 +183,36 @@ func (t *Type) groom(defaultValueCel Cel, label string) error {
 			return err
 		}
 	}
+	if t.Quantity != nil {
+		if myType != "" {
+			return fmt.Errorf("can't be '%s' and 'quantity'", myType)
+		}
+		myType = "quantity"
+		err := t.Quantity.groom(defaultValueCel, label)
+		if err != nil {
+			return err
+		}
+	}
+	if t.Reference != nil {
+		if myType != "" {
+			return fmt.Errorf("can't be '%s' and 'reference'", myType)
+		}
+		myType = "reference"
+		err := t.Reference.groom(defaultValueCel, label)
+		if err != nil {
+			return err
+		}
+	}
+	if t.Secret != nil {
+		if myType != "" {
+			return fmt.Errorf("can't be '%s' and 'secret'", myType)
+		}
+		myType = "secret"
+		err := t.Secret.groom(defaultValueCel, label)
+		if err != nil {
+			return err
+		}
+	}
 	if myType == "" && t.String == nil {
 		t.String = &FieldString{}
 	}
@@ -142,3 +225,16 @@ func (t *Type) groom(defaultValueCel Cel, label string) error {
 	}
 	return nil
 }
+
+// enumSize return the number of allowed values, or 0 if the type is not an enum one.
+func (t *Type) enumSize() int {
+	switch {
+	case t.String != nil:
+		return len(t.String.Enum)
+	case t.Integer != nil:
+		return len(t.Integer.Enum)
+	case t.Number != nil:
+		return len(t.Number.Enum)
+	}
+	return 0
+}
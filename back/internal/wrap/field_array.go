@@ -7,7 +7,12 @@ type FieldArray struct {
 		Validation *Validation `yaml:"validation,omitempty" json:"validation,omitempty"`
 		Type       Type        `yaml:",inline" json:",inline"`
 	} `yaml:"item" json:"item"`
-	InList *struct {
+	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
+	MinItems    int         `yaml:"minItems,omitempty" json:"minItems,omitempty"`
+	MaxItems    int         `yaml:"maxItems,omitempty" json:"maxItems,omitempty"` // No limit if 0
+	UniqueItems bool        `yaml:"uniqueItems,omitempty" json:"uniqueItems,omitempty"`
+	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	InList      *struct {
 		Hidden    bool        `yaml:"hidden,omitempty" json:"hidden,omitempty"`
 		Header    string      `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
 		Display   UiComponent `yaml:"display,omitempty" json:"display,omitempty"`
@@ -15,20 +20,53 @@ type FieldArray struct {
 		Height    int         `yaml:"height,omitempty" json:"height,omitempty"`
 		Value     Cel         `yaml:"value,omitempty" json:"value,omitempty"`
 		Alignment Alignment   `yaml:"alignment,omitempty" json:"alignment,omitempty"`
-	}
+	} `yaml:"inlist,omitempty" json:"InList,omitempty"` // 'InList' is the json key of the former untagged field, kept for compatibility
 }
 
+// itemValueCel is the default value of array items, and the base path of object items.
+// Item expressions are evaluated with the current item exposed as 'item'.
+const itemValueCel Cel = "item"
+
 func (f *FieldArray) groom(defaultValueCel Cel, label string) error {
-	if f.Item.Validation == nil {
+	if f.Item.Validation != nil {
 		err := f.Item.Validation.groom()
 		if err != nil {
 			return fmt.Errorf("invalid validation: %w", err)
 		}
 	}
-	err := f.Item.Type.groom(defaultValueCel, label)
+	if f.Value == "" {
+		f.Value = defaultValueCel
+	}
+	err := validCel(f.Value)
 	if err != nil {
 		return err
 	}
+	err = f.Item.Type.groom(itemValueCel, label)
+	if err != nil {
+		return err
+	}
+	hasSecret := f.Item.Type.Secret != nil
+	if f.Item.Type.Object != nil {
+		visitSecrets(f.Item.Type.Object.Fields, "", func(string, *Field) { hasSecret = true })
+	}
+	if hasSecret {
+		return fmt.Errorf("secrets are not supported in array items")
+	}
+	if f.MinItems < 0 || f.MaxItems < 0 {
+		return fmt.Errorf("minItems and maxItems must not be negative")
+	}
+	if f.MaxItems != 0 && f.MaxItems < f.MinItems {
+		return fmt.Errorf("maxItems '%d' is lower than minItems '%d'", f.MaxItems, f.MinItems)
+	}
+	if f.UiComponent == "" {
+		f.UiComponent = "list"
+	}
+	if !validArrayUiComponents[f.UiComponent] {
+		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
+	}
+	if f.UiComponent == "multiSelect" && f.Item.Type.enumSize() == 0 && f.Item.Type.Reference == nil {
+		return fmt.Errorf("UiComponent 'multiSelect' requires items with an enum, or references")
+	}
 	if f.InList != nil {
 		if f.InList.Header == "" {
 			f.InList.Header = label
@@ -40,7 +78,7 @@ func (f *FieldArray) groom(defaultValueCel Cel, label string) error {
 			f.InList.Height = 1
 		}
 		if f.InList.Value == "" {
-			f.InList.Value = "'[...]'"
+			f.InList.Value = f.defaultInListValue()
 		}
 		err := validCel(f.InList.Value)
 		if err != nil {
@@ -56,3 +94,58 @@ func (f *FieldArray) groom(defaultValueCel Cel, label string) error {
 	}
 	return nil
 }
+
+// defaultInListValue build a summary of the items: the item values, or the object items summaries, joined by ', '
+func (f *FieldArray) defaultInListValue() Cel {
+	itemSummary := Cel("string(item)")
+	if object := f.Item.Type.Object; object != nil {
+		if object.InList == nil {
+			return Cel(fmt.Sprintf("string(size(%s)) + ' item(s)'", f.Value))
+		}
+		itemSummary = object.InList.Value
+	}
+	return Cel(fmt.Sprintf("(%s).map(item, %s).join(', ')", f.Value, itemSummary))
+}
+
+var validArrayUiComponents = map[UiComponent]bool{
+	"list":        true,
+	"multiSelect": true,
+}
+
+func (f *FieldArray) check(path string, value interface{}, vc *valueChecker) interface{} {
+	items, ok := value.([]interface{})
+	if !ok {
+		vc.add(path, "must be an array")
+		return nil
+	}
+	if len(items) < f.MinItems {
+		vc.add(path, "must have at least %d items", f.MinItems)
+	}
+	if f.MaxItems != 0 && len(items) > f.MaxItems {
+		vc.add(path, "must have at most %d items", f.MaxItems)
+	}
+	result := make([]interface{}, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for idx, item := range items {
+		itemPath := fmt.Sprintf("%s[%d]", path, idx)
+		if isEmptyValue(item) {
+			vc.add(itemPath, "empty item")
+			continue
+		}
+		errCount := len(vc.errs)
+		checked := f.Item.Type.check(itemPath, item, vc)
+		if f.Item.Validation != nil && len(vc.errs) == errCount {
+			vc.validate(itemPath, f.Item.Validation, map[string]interface{}{"self": celValue(checked), "fields": vc.root})
+		}
+		// A multiSelect is a set of choices
+		if f.UniqueItems || f.UiComponent == "multiSelect" {
+			key := fmt.Sprintf("%v", checked)
+			if seen[key] {
+				vc.add(itemPath, "duplicated item")
+			}
+			seen[key] = true
+		}
+		result = append(result, checked)
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package wrap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestArrayOfObjects(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  valuePath: ".data."
+  fields:
+    - name: name
+    - name: tags
+      array:
+        inlist: {}
+        item:
+          string: {}
+    - name: ports
+      array:
+        inlist: {}
+        item:
+          object:
+            inList:
+              value: "item.name + ':' + string(item.port)"
+            fields:
+              - name: name
+                required: true
+              - name: port
+                required: true
+                integer:
+                  min: 1
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .Fields.name }}
+  data:
+    ports: |
+      {{- range .Fields.ports }}
+      {{ .name }}={{ .port }}
+      {{- end }}
+`)
+	tags := w.Schema.Fields[1].Type.Array
+	if tags.Value != ".data.tags" || tags.Item.Type.String.Value != "item" {
+		t.Errorf("Unexpected value paths: %s, %s", tags.Value, tags.Item.Type.String.Value)
+	}
+	ports := w.Schema.Fields[2].Type.Array
+	object := ports.Item.Type.Object
+	if object.BasePath != "item" || object.Fields[1].Type.Integer.Value != "item.port" {
+		t.Errorf("Unexpected item paths: %s, %s", object.BasePath, object.Fields[1].Type.Integer.Value)
+	}
+
+	activation := resourceActivation(map[string]interface{}{
+		"data": map[string]interface{}{
+			"tags":  []interface{}{"a", "b"},
+			"ports": []interface{}{map[string]interface{}{"name": "http", "port": 80}, map[string]interface{}{"name": "https", "port": 443}},
+		},
+	})
+	for _, tc := range []struct {
+		exp      Cel
+		expected string
+	}{
+		{tags.InList.Value, "a, b"},
+		{ports.InList.Value, "http:80, https:443"},
+	} {
+		summary, err := evalCel(tc.exp, activation)
+		if err != nil || summary != tc.expected {
+			t.Errorf("%s: expected '%s', got '%v' (%v)", tc.exp, tc.expected, summary, err)
+		}
+	}
+
+	_, _, err := w.CheckValues(decodeValues(t, `{"ports": [{"name": "http"}, {"name": "https", "port": 0, "other": 1}]}`), nil)
+	checkErrorPaths(t, err, "ports[0].port", "ports[1].port", "ports[1].other")
+
+	values, _, err := w.CheckValues(decodeValues(t, `{"name": "cm1", "ports": [{"name": "http", "port": 80}, {"name": "https", "port": 443}]}`), nil)
+	checkErrorPaths(t, err)
+	set, err := w.Render(&TemplateData{Fields: values, Metadata: map[string]interface{}{}})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	obj := set.Primary
+	if data := obj.Object["data"].(map[string]interface{}); data["ports"] != "http=80\nhttps=443\n" {
+		t.Errorf("Unexpected data: %#v", data)
+	}
+}
+
+func TestArrayItemValidation(t *testing.T) {
+	// Items without validation
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: tags
+      array:
+        inlist: {}
+        item:
+          string: {}
+`)
+	data, err := json.Marshal(w.Schema.Fields[0].Type.Array)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"InList":{`) {
+		t.Errorf("Unexpected json: %s", data)
+	}
+
+	w = parseTestWrap(t, `
+schema:
+  fields:
+    - name: tags
+      array:
+        item:
+          validation:
+            test: "self.startsWith('t-')"
+            message: "must start with 't-'"
+          string: {}
+`)
+	_, _, err = w.CheckValues(decodeValues(t, `{"tags": ["t-a", "b", "t-c"]}`), nil)
+	checkErrorPaths(t, err, "tags[1]")
+
+	_, err = Parse([]byte(testWrapHeader+`
+schema:
+  fields:
+    - name: tags
+      array:
+        item:
+          validation:
+            test: "self.startsWith("
+          string: {}
+`), "test")
+	if err == nil {
+		t.Error("Expected an error on invalid item validation")
+	}
+}
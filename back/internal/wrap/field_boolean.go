@@ -3,7 +3,7 @@ package wrap
 import "fmt"
 
 type FieldBoolean struct {
-	Default     bool        `yaml:"default,omitempty" json:"default,omitempty"`
+	Default     *bool       `yaml:"default,omitempty" json:"default,omitempty"` // A pointer, as 'false' is a default too
 	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
 	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
 	Inlist      *struct {
@@ -44,3 +44,12 @@ func (f *FieldBoolean) groom(defaultValueCel Cel, label string) error {
 var validBooleanUiComponents = map[UiComponent]bool{
 	"checkbox": true,
 }
+
+func (f *FieldBoolean) check(path string, value interface{}, vc *valueChecker) interface{} {
+	b, ok := value.(bool)
+	if !ok {
+		vc.add(path, "must be a boolean")
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,118 @@
+package wrap
+
+import (
+	"fmt"
+	"time"
+)
+
+// FieldDateTime is a RFC3339 timestamp (i.e. '2025-06-30T12:00:00Z')
+type FieldDateTime struct {
+	Default     string      `yaml:"default,omitempty" json:"default,omitempty"`
+	Min         string      `yaml:"min,omitempty" json:"min,omitempty"`
+	Max         string      `yaml:"max,omitempty" json:"max,omitempty"`
+	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Inlist      *struct {
+		Hidden      bool        `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+		Header      string      `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
+		UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+		Alignment   Alignment   `yaml:"alignment,omitempty" json:"alignment,omitempty"`
+	} `yaml:"inlist,omitempty" json:"inlist,omitempty"`
+
+	min *time.Time
+	max *time.Time
+}
+
+func (f *FieldDateTime) groom(defaultValueCel Cel, label string) error {
+	if f.Value == "" {
+		f.Value = defaultValueCel
+	}
+	err := validCel(f.Value)
+	if err != nil {
+		return err
+	}
+	f.min, err = parseDateTimeBound(f.Min)
+	if err != nil {
+		return fmt.Errorf("invalid min: %w", err)
+	}
+	f.max, err = parseDateTimeBound(f.Max)
+	if err != nil {
+		return fmt.Errorf("invalid max: %w", err)
+	}
+	if f.min != nil && f.max != nil && f.max.Before(*f.min) {
+		return fmt.Errorf("max '%s' is before min '%s'", f.Max, f.Min)
+	}
+	if f.Default != "" {
+		if msg := f.checkDateTime(f.Default); msg != "" {
+			return fmt.Errorf("default value: %s", msg)
+		}
+	}
+	if f.UiComponent == "" {
+		f.UiComponent = "dateTime"
+	}
+	if !validDateTimeUiComponents[f.UiComponent] {
+		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
+	}
+	if f.Inlist != nil {
+		if f.Inlist.Header == "" {
+			f.Inlist.Header = label
+		}
+		if f.Inlist.UiComponent == "" {
+			f.Inlist.UiComponent = f.UiComponent
+		}
+		if !validDateTimeUiComponents[f.Inlist.UiComponent] {
+			return fmt.Errorf("invalid UiComponent: %s", f.Inlist.UiComponent)
+		}
+		if f.Inlist.Alignment == "" {
+			f.Inlist.Alignment = "left"
+		}
+		err = validAlignment(f.Inlist.Alignment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var validDateTimeUiComponents = map[UiComponent]bool{
+	"dateTime": true,
+	"date":     true,
+}
+
+func parseDateTimeBound(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// checkDateTime return an error message, or "" if the value is valid
+func (f *FieldDateTime) checkDateTime(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Sprintf("'%s' is not a RFC3339 date-time (i.e. '2006-01-02T15:04:05Z')", s)
+	}
+	if f.min != nil && t.Before(*f.min) {
+		return fmt.Sprintf("must not be before %s", f.Min)
+	}
+	if f.max != nil && t.After(*f.max) {
+		return fmt.Sprintf("must not be after %s", f.Max)
+	}
+	return ""
+}
+
+func (f *FieldDateTime) check(path string, value interface{}, vc *valueChecker) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		vc.add(path, "must be a string")
+		return nil
+	}
+	if msg := f.checkDateTime(s); msg != "" {
+		vc.add(path, "%s", msg)
+	}
+	return s
+}
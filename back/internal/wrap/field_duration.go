@@ -1,14 +1,68 @@
 package wrap
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Duration is a time.Duration read and written as a Go duration string (i.e. '5m' or '1h30m')
+type Duration time.Duration
+
+func (d Duration) String() string {
+	s := time.Duration(d).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
 type FieldDuration struct {
-	Default     time.Duration `yaml:"default,omitempty" json:"default,omitempty"`
-	Value       Cel           `yaml:"value,omitempty" json:"value,omitempty"`
-	UiComponent UiComponent   `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Default     Duration    `yaml:"default,omitempty" json:"default,omitempty"`
+	Min         Duration    `yaml:"min,omitempty" json:"min,omitempty"`
+	Max         Duration    `yaml:"max,omitempty" json:"max,omitempty"` // No limit if 0
+	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
 	InList      *struct {
 		Hidden      bool        `yaml:"hidden,omitempty" json:"hidden,omitempty"`
 		Header      string      `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
@@ -25,6 +79,17 @@ func (f *FieldDuration) groom(defaultValueCel Cel, label string) error {
 	if err != nil {
 		return err
 	}
+	if f.Min < 0 || f.Max < 0 {
+		return fmt.Errorf("min and max must not be negative")
+	}
+	if f.Max != 0 && f.Max < f.Min {
+		return fmt.Errorf("max '%s' is lower than min '%s'", f.Max, f.Min)
+	}
+	if f.Default != 0 {
+		if msg := f.checkDuration(f.Default); msg != "" {
+			return fmt.Errorf("default value: %s", msg)
+		}
+	}
 	if f.UiComponent == "" {
 		f.UiComponent = "raw"
 	}
@@ -55,3 +120,31 @@ func (f *FieldDuration) groom(defaultValueCel Cel, label string) error {
 var validDurationUiComponents = map[UiComponent]bool{
 	"raw": true,
 }
+
+func (f *FieldDuration) check(path string, value interface{}, vc *valueChecker) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		vc.add(path, "must be a string")
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		vc.add(path, "'%s' is not a valid duration (i.e. '30s', '5m' or '1h30m')", s)
+		return nil
+	}
+	if msg := f.checkDuration(Duration(d)); msg != "" {
+		vc.add(path, "%s", msg)
+	}
+	return s
+}
+
+// checkDuration return an error message, or "" if the value is in bounds
+func (f *FieldDuration) checkDuration(d Duration) string {
+	if d < f.Min {
+		return fmt.Sprintf("must be at least %s", f.Min)
+	}
+	if f.Max != 0 && d > f.Max {
+		return fmt.Sprintf("must be at most %s", f.Max)
+	}
+	return ""
+}
@@ -3,10 +3,13 @@ package wrap
 import "fmt"
 
 type FieldInteger struct {
-	Default     *int        `yaml:"default,omitempty" json:"default,omitempty"`
-	Enum        []int       `yaml:"enum,omitempty" json:"enum,omitempty"`
-	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
-	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Default     *int            `yaml:"default,omitempty" json:"default,omitempty"`
+	Enum        []EnumItem[int] `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Min         *int            `yaml:"min,omitempty" json:"min,omitempty"`
+	Max         *int            `yaml:"max,omitempty" json:"max,omitempty"`
+	Step        int             `yaml:"step,omitempty" json:"step,omitempty"`
+	Value       Cel             `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent UiComponent     `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
 	Inlist      *struct {
 		Hidden      bool        `yaml:"hidden,omitempty" json:"hidden,omitempty"`
 		Header      string      `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
@@ -23,12 +26,36 @@ func (f *FieldInteger) groom(defaultValueCel Cel, label string) error {
 	if err != nil {
 		return err
 	}
+	err = groomEnum(f.Enum)
+	if err != nil {
+		return err
+	}
+	if f.Default != nil && len(f.Enum) > 0 && !enumContains(f.Enum, *f.Default) {
+		return fmt.Errorf("default value '%d' is not in enum", *f.Default)
+	}
+	err = groomBounds(f.Min, f.Max, f.Step)
+	if err != nil {
+		return err
+	}
+	if f.Default != nil {
+		if msg := checkBounds(*f.Default, f.Min, f.Max, f.Step); msg != "" {
+			return fmt.Errorf("default value: %s", msg)
+		}
+	}
 	if f.UiComponent == "" {
-		f.UiComponent = "raw"
+		if len(f.Enum) > 0 {
+			f.UiComponent = "select"
+		} else {
+			f.UiComponent = "raw"
+		}
 	}
 	if !validIntegerUiComponents[f.UiComponent] {
 		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
 	}
+	err = validEnumUiComponent(f.UiComponent, len(f.Enum))
+	if err != nil {
+		return err
+	}
 	if f.Inlist != nil {
 		if f.Inlist.Header == "" {
 			f.Inlist.Header = label
@@ -51,5 +78,22 @@ func (f *FieldInteger) groom(defaultValueCel Cel, label string) error {
 }
 
 var validIntegerUiComponents = map[UiComponent]bool{
-	"raw": true,
+	"raw":    true,
+	"select": true,
+	"radio":  true,
+}
+
+func (f *FieldInteger) check(path string, value interface{}, vc *valueChecker) interface{} {
+	i, ok := toInt(value)
+	if !ok {
+		vc.add(path, "must be an integer")
+		return nil
+	}
+	if len(f.Enum) > 0 && !enumContains(f.Enum, i) {
+		vc.add(path, "'%d' is not an allowed value", i)
+	}
+	if msg := checkBounds(i, f.Min, f.Max, f.Step); msg != "" {
+		vc.add(path, "%s", msg)
+	}
+	return i
 }
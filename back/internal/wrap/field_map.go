@@ -0,0 +1,161 @@
+package wrap
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FieldMap is a map[string]string (i.e. labels, annotations, ...)
+type FieldMap struct {
+	Default      map[string]string  `yaml:"default,omitempty" json:"default,omitempty"`
+	KeyPattern   string             `yaml:"keyPattern,omitempty" json:"keyPattern,omitempty"`     // Regex each key must match
+	ValuePattern string             `yaml:"valuePattern,omitempty" json:"valuePattern,omitempty"` // Regex each value must match
+	KeyEnum      []EnumItem[string] `yaml:"keyEnum,omitempty" json:"keyEnum,omitempty"`           // If defined, the only allowed keys
+	Value        Cel                `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent  UiComponent        `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Width        int                `yaml:"width,omitempty" json:"width,omitempty"`
+	Inlist       *struct {
+		Hidden    bool      `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+		Header    string    `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
+		Alignment Alignment `yaml:"alignment,omitempty" json:"alignment,omitempty"`
+		Value     Cel       `yaml:"value,omitempty" json:"value,omitempty"` // Default to a 'key=value, ...' summary
+		Width     int       `yaml:"width,omitempty" json:"width,omitempty"`
+	} `yaml:"inlist,omitempty" json:"inlist,omitempty"`
+
+	keyRegex   *regexp.Regexp
+	valueRegex *regexp.Regexp
+}
+
+func (f *FieldMap) groom(defaultValueCel Cel, label string) error {
+	if f.Value == "" {
+		f.Value = defaultValueCel
+	}
+	err := validCel(f.Value)
+	if err != nil {
+		return err
+	}
+	if f.KeyPattern != "" {
+		f.keyRegex, err = regexp.Compile(f.KeyPattern)
+		if err != nil {
+			return fmt.Errorf("invalid keyPattern: %w", err)
+		}
+	}
+	if f.ValuePattern != "" {
+		f.valueRegex, err = regexp.Compile(f.ValuePattern)
+		if err != nil {
+			return fmt.Errorf("invalid valuePattern: %w", err)
+		}
+	}
+	err = groomEnum(f.KeyEnum)
+	if err != nil {
+		return err
+	}
+	for _, k := range sortedKeys(f.Default) {
+		if msg := f.checkEntry(k, f.Default[k]); msg != "" {
+			return fmt.Errorf("default value: %s", msg)
+		}
+	}
+	if f.Width == 0 {
+		f.Width = 30
+	}
+	if f.UiComponent == "" {
+		f.UiComponent = "keyValue"
+	}
+	if !validMapUiComponents[f.UiComponent] {
+		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
+	}
+	if f.Inlist != nil {
+		if f.Inlist.Header == "" {
+			f.Inlist.Header = label
+		}
+		if f.Inlist.Alignment == "" {
+			f.Inlist.Alignment = "left"
+		}
+		err = validAlignment(f.Inlist.Alignment)
+		if err != nil {
+			return err
+		}
+		if f.Inlist.Value == "" {
+			f.Inlist.Value = f.defaultInlistValue()
+		}
+		err = validCel(f.Inlist.Value)
+		if err != nil {
+			return err
+		}
+		if f.Inlist.Width == 0 {
+			f.Inlist.Width = f.Width
+		}
+	}
+	return nil
+}
+
+var selectionRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+
+// defaultInlistValue build a 'key=value, ...' summary, sorted by key (map iteration order is not stable).
+// If the value is a plain selection (i.e. resource.metadata.labels), the summary is empty when the map is absent.
+func (f *FieldMap) defaultInlistValue() Cel {
+	summary := fmt.Sprintf("(%s).map(k, k).sort().map(k, k + '=' + (%s)[k]).join(', ')", f.Value, f.Value)
+	if !selectionRegex.MatchString(string(f.Value)) {
+		return Cel(summary)
+	}
+	elements := strings.Split(string(f.Value), ".")
+	guards := make([]string, 0, len(elements)-1)
+	for i := 2; i <= len(elements); i++ {
+		guards = append(guards, fmt.Sprintf("has(%s)", strings.Join(elements[:i], ".")))
+	}
+	return Cel(fmt.Sprintf("%s ? %s : ''", strings.Join(guards, " && "), summary))
+}
+
+var validMapUiComponents = map[UiComponent]bool{
+	"keyValue": true,
+}
+
+// checkEntry return an error message, or "" if the entry is valid
+func (f *FieldMap) checkEntry(key string, value string) string {
+	if key == "" {
+		return "empty key"
+	}
+	if len(f.KeyEnum) > 0 && !enumContains(f.KeyEnum, key) {
+		return fmt.Sprintf("'%s' is not an allowed key", key)
+	}
+	if f.keyRegex != nil && !f.keyRegex.MatchString(key) {
+		return fmt.Sprintf("key '%s' does not match '%s'", key, f.KeyPattern)
+	}
+	if f.valueRegex != nil && !f.valueRegex.MatchString(value) {
+		return fmt.Sprintf("value '%s' does not match '%s'", value, f.ValuePattern)
+	}
+	return ""
+}
+
+func (f *FieldMap) check(path string, value interface{}, vc *valueChecker) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		vc.add(path, "must be an object")
+		return nil
+	}
+	result := make(map[string]interface{}, len(m))
+	for _, k := range sortedKeys(m) {
+		s, ok := m[k].(string)
+		if !ok {
+			vc.add(joinFieldPath(path, k), "must be a string")
+			continue
+		}
+		if msg := f.checkEntry(k, s); msg != "" {
+			vc.add(joinFieldPath(path, k), "%s", msg)
+			continue
+		}
+		result[k] = s
+	}
+	return result
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
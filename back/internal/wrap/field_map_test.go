@@ -0,0 +1,65 @@
+package wrap
+
+import (
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: name
+    - name: labels
+      map:
+        value: "resource.metadata.labels"
+        keyPattern: "^[a-z]+$"
+        inlist: {}
+    - name: settings
+      map:
+        keyEnum: [mode, level]
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .Fields.name }}
+    {{- with .Fields.labels }}
+    labels:
+      {{- toYaml . | nindent 6 }}
+    {{- end }}
+  data:
+    {{- toYaml .Fields.settings | nindent 4 }}
+`)
+	labels := w.Schema.Fields[1].Type.Map
+	summary, err := evalCel(labels.Inlist.Value, resourceActivation(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"tier": "web", "app": "x", "env": "dev"}},
+	}))
+	if err != nil || summary != "app=x, env=dev, tier=web" {
+		t.Errorf("Unexpected inlist summary evaluation: %v, %v", summary, err)
+	}
+	// Absent map
+	for _, resource := range []map[string]interface{}{{"metadata": map[string]interface{}{}}, {}} {
+		summary, err = evalCel(labels.Inlist.Value, resourceActivation(resource))
+		if err != nil || summary != "" {
+			t.Errorf("Unexpected inlist summary of %v: %v, %v", resource, summary, err)
+		}
+	}
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"labels": {"app": "x", "Bad": "y", "num": 1}, "settings": {"mode": "a", "other": "b"}}`), nil)
+	checkErrorPaths(t, err, "labels.Bad", "labels.num", "settings.other")
+
+	values, _, err := w.CheckValues(decodeValues(t, `{"name": "cm1", "labels": {"app": "x"}, "settings": {"mode": "true", "level": "3"}}`), nil)
+	checkErrorPaths(t, err)
+	set, err := w.Render(&TemplateData{Fields: values, Metadata: map[string]interface{}{}})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	obj := set.Primary
+	if obj.GetLabels()["app"] != "x" {
+		t.Errorf("Unexpected labels: %v", obj.GetLabels())
+	}
+	// Values looking like other types must stay strings
+	data := obj.Object["data"].(map[string]interface{})
+	if data["mode"] != "true" || data["level"] != "3" {
+		t.Errorf("Unexpected data: %#v", data)
+	}
+}
@@ -3,11 +3,14 @@ package wrap
 import "fmt"
 
 type FieldNumber struct {
-	Default     *float64    `yaml:"default,omitempty" json:"default,omitempty"`
-	Enum        []float64   `yaml:"enum,omitempty" json:"enum,omitempty"`
-	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
-	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
-	Format      string      `yaml:"format,omitempty" json:"format,omitempty"` // fmt.Sprintf format expression
+	Default     *float64            `yaml:"default,omitempty" json:"default,omitempty"`
+	Enum        []EnumItem[float64] `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Min         *float64            `yaml:"min,omitempty" json:"min,omitempty"`
+	Max         *float64            `yaml:"max,omitempty" json:"max,omitempty"`
+	Step        float64             `yaml:"step,omitempty" json:"step,omitempty"`
+	Value       Cel                 `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent UiComponent         `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Format      string              `yaml:"format,omitempty" json:"format,omitempty"` // fmt.Sprintf format expression
 	Inlist      *struct {
 		Hidden      bool        `yaml:"hidden,omitempty" json:"hidden,omitempty"`
 		Header      string      `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
@@ -25,12 +28,36 @@ func (f *FieldNumber) groom(defaultValueCel Cel, label string) error {
 	if err != nil {
 		return err
 	}
+	err = groomEnum(f.Enum)
+	if err != nil {
+		return err
+	}
+	if f.Default != nil && len(f.Enum) > 0 && !enumContains(f.Enum, *f.Default) {
+		return fmt.Errorf("default value '%v' is not in enum", *f.Default)
+	}
+	err = groomBounds(f.Min, f.Max, f.Step)
+	if err != nil {
+		return err
+	}
+	if f.Default != nil {
+		if msg := checkBounds(*f.Default, f.Min, f.Max, f.Step); msg != "" {
+			return fmt.Errorf("default value: %s", msg)
+		}
+	}
 	if f.UiComponent == "" {
-		f.UiComponent = "raw"
+		if len(f.Enum) > 0 {
+			f.UiComponent = "select"
+		} else {
+			f.UiComponent = "raw"
+		}
 	}
 	if !validNumberUiComponents[f.UiComponent] {
 		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
 	}
+	err = validEnumUiComponent(f.UiComponent, len(f.Enum))
+	if err != nil {
+		return err
+	}
 	if f.Format == "" {
 		f.Format = "%f"
 	}
@@ -59,5 +86,22 @@ func (f *FieldNumber) groom(defaultValueCel Cel, label string) error {
 }
 
 var validNumberUiComponents = map[UiComponent]bool{
-	"raw": true,
+	"raw":    true,
+	"select": true,
+	"radio":  true,
+}
+
+func (f *FieldNumber) check(path string, value interface{}, vc *valueChecker) interface{} {
+	n, ok := toFloat(value)
+	if !ok {
+		vc.add(path, "must be a number")
+		return nil
+	}
+	if len(f.Enum) > 0 && !enumContains(f.Enum, n) {
+		vc.add(path, "'%v' is not an allowed value", n)
+	}
+	if msg := checkBounds(n, f.Min, f.Max, f.Step); msg != "" {
+		vc.add(path, "%s", msg)
+	}
+	return n
 }
@@ -33,6 +33,9 @@ func (f *FieldObject) groom(defaultValueCel Cel, label string) error {
 			return fmt.Errorf("field '%s': %v", f.Fields[idx].Name, err)
 		}
 	}
+	if _, err := computeOrder(f.Fields, false); err != nil {
+		return err
+	}
 	if f.UiComponent == "" {
 		f.UiComponent = "fieldSet"
 	}
@@ -69,3 +72,12 @@ var validObjectCardUiComponents = map[UiComponent]bool{
 var validObjectListUiComponents = map[UiComponent]bool{
 	"raw": true,
 }
+
+func (f *FieldObject) check(path string, value interface{}, vc *valueChecker) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		vc.add(path, "must be an object")
+		return nil
+	}
+	return checkFields(f.Fields, m, path, vc)
+}
@@ -0,0 +1,148 @@
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// FieldQuantity is a k8s resource quantity (i.e. '512Mi' or '100m')
+type FieldQuantity struct {
+	Default     string      `yaml:"default,omitempty" json:"default,omitempty"`
+	Min         string      `yaml:"min,omitempty" json:"min,omitempty"`
+	Max         string      `yaml:"max,omitempty" json:"max,omitempty"`
+	Units       []string    `yaml:"units,omitempty" json:"units,omitempty"` // If defined, the allowed suffixes (i.e. ['Mi', 'Gi']). "" for none
+	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Inlist      *struct {
+		Hidden      bool        `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+		Header      string      `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
+		UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+		Alignment   Alignment   `yaml:"alignment,omitempty" json:"alignment,omitempty"`
+	} `yaml:"inlist,omitempty" json:"inlist,omitempty"`
+
+	min *resource.Quantity
+	max *resource.Quantity
+}
+
+func (f *FieldQuantity) groom(defaultValueCel Cel, label string) error {
+	if f.Value == "" {
+		f.Value = defaultValueCel
+	}
+	err := validCel(f.Value)
+	if err != nil {
+		return err
+	}
+	f.min, err = parseQuantityBound(f.Min)
+	if err != nil {
+		return fmt.Errorf("invalid min: %w", err)
+	}
+	f.max, err = parseQuantityBound(f.Max)
+	if err != nil {
+		return fmt.Errorf("invalid max: %w", err)
+	}
+	if f.min != nil && f.max != nil && f.max.Cmp(*f.min) < 0 {
+		return fmt.Errorf("max '%s' is lower than min '%s'", f.Max, f.Min)
+	}
+	if f.Default != "" {
+		if msg := f.checkQuantity(f.Default); msg != "" {
+			return fmt.Errorf("default value: %s", msg)
+		}
+	}
+	if f.UiComponent == "" {
+		f.UiComponent = "raw"
+	}
+	if !validQuantityUiComponents[f.UiComponent] {
+		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
+	}
+	if f.Inlist != nil {
+		if f.Inlist.Header == "" {
+			f.Inlist.Header = label
+		}
+		if f.Inlist.UiComponent == "" {
+			f.Inlist.UiComponent = f.UiComponent
+		}
+		if !validQuantityUiComponents[f.Inlist.UiComponent] {
+			return fmt.Errorf("invalid UiComponent: %s", f.Inlist.UiComponent)
+		}
+		if f.Inlist.Alignment == "" {
+			f.Inlist.Alignment = "right"
+		}
+		err = validAlignment(f.Inlist.Alignment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var validQuantityUiComponents = map[UiComponent]bool{
+	"raw": true,
+}
+
+func parseQuantityBound(s string) (*resource.Quantity, error) {
+	if s == "" {
+		return nil, nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// quantitySuffix return the unit part of a parsed quantity (i.e. 'Mi' for '512Mi').
+// Exponent forms (i.e. '1e3') are plain numbers, without unit.
+func quantitySuffix(s string, q resource.Quantity) string {
+	if q.Format == resource.DecimalExponent {
+		return ""
+	}
+	// The quantity is valid, so letters are the suffix
+	idx := strings.IndexFunc(s, unicode.IsLetter)
+	if idx < 0 {
+		return ""
+	}
+	return s[idx:]
+}
+
+// checkQuantity return an error message, or "" if the value is valid
+func (f *FieldQuantity) checkQuantity(s string) string {
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return fmt.Sprintf("'%s' is not a valid quantity", s)
+	}
+	if len(f.Units) > 0 && !slices.Contains(f.Units, quantitySuffix(s, q)) {
+		return fmt.Sprintf("unit of '%s' is not one of %s", s, strings.Join(f.Units, ", "))
+	}
+	if f.min != nil && q.Cmp(*f.min) < 0 {
+		return fmt.Sprintf("must be at least %s", f.Min)
+	}
+	if f.max != nil && q.Cmp(*f.max) > 0 {
+		return fmt.Sprintf("must be at most %s", f.Max)
+	}
+	return ""
+}
+
+// check accept a string or a number (i.e. a cpu count), and return it as a string
+func (f *FieldQuantity) check(path string, value interface{}, vc *valueChecker) interface{} {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case int, int64, float64:
+		s = fmt.Sprintf("%v", v)
+	default:
+		vc.add(path, "must be a quantity")
+		return nil
+	}
+	if msg := f.checkQuantity(s); msg != "" {
+		vc.add(path, "%s", msg)
+	}
+	return s
+}
@@ -0,0 +1,196 @@
+package wrap
+
+import (
+	"context"
+	"fmt"
+	"krapper/internal/k8s"
+	"sort"
+)
+
+// FieldReference is a value picked from the existing objects of another wrap (Or of an apiVersion/kind).
+type FieldReference struct {
+	// Either Wrap or ApiVersion/Kind must be defined
+	Wrap       string            `yaml:"wrap,omitempty" json:"wrap,omitempty"`
+	ApiVersion string            `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Kind       string            `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Namespace  string            `yaml:"namespace,omitempty" json:"namespace,omitempty"` // Default to the namespace of the edited object
+	Selector   map[string]string `yaml:"selector,omitempty" json:"selector,omitempty"`
+	// Evaluated against each candidate object (exposed as 'resource')
+	OptionValue Cel         `yaml:"optionValue,omitempty" json:"optionValue,omitempty"` // Default to 'resource.metadata.name'
+	OptionLabel Cel         `yaml:"optionLabel,omitempty" json:"optionLabel,omitempty"` // Default to optionValue
+	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Width       int         `yaml:"width,omitempty" json:"width,omitempty"`
+	Inlist      *struct {
+		Hidden    bool      `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+		Header    string    `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
+		Alignment Alignment `yaml:"alignment,omitempty" json:"alignment,omitempty"`
+		Width     int       `yaml:"width,omitempty" json:"width,omitempty"`
+	} `yaml:"inlist,omitempty" json:"inlist,omitempty"`
+}
+
+// Option is a candidate value of a reference field
+type Option struct {
+	Value string `yaml:"value" json:"value"`
+	Label string `yaml:"label" json:"label"`
+}
+
+// WrapLookup return a wrap by name, or nil if not found
+type WrapLookup func(name string) *Wrap
+
+func (f *FieldReference) groom(defaultValueCel Cel, label string) error {
+	if f.Wrap == "" && (f.ApiVersion == "" || f.Kind == "") {
+		return fmt.Errorf("reference must define either a wrap or an apiVersion/kind")
+	}
+	if f.Wrap != "" && (f.ApiVersion != "" || f.Kind != "") {
+		return fmt.Errorf("reference can't define both a wrap and an apiVersion/kind")
+	}
+	if f.Value == "" {
+		f.Value = defaultValueCel
+	}
+	err := validCel(f.Value)
+	if err != nil {
+		return err
+	}
+	if f.OptionValue == "" {
+		f.OptionValue = "resource.metadata.name"
+	}
+	err = validCel(f.OptionValue)
+	if err != nil {
+		return fmt.Errorf("invalid optionValue: %w", err)
+	}
+	if f.OptionLabel == "" {
+		f.OptionLabel = f.OptionValue
+	}
+	err = validCel(f.OptionLabel)
+	if err != nil {
+		return fmt.Errorf("invalid optionLabel: %w", err)
+	}
+	if f.Width == 0 {
+		f.Width = 30
+	}
+	if f.UiComponent == "" {
+		f.UiComponent = "select"
+	}
+	if !validReferenceUiComponents[f.UiComponent] {
+		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
+	}
+	if f.Inlist != nil {
+		if f.Inlist.Header == "" {
+			f.Inlist.Header = label
+		}
+		if f.Inlist.Alignment == "" {
+			f.Inlist.Alignment = "left"
+		}
+		err = validAlignment(f.Inlist.Alignment)
+		if err != nil {
+			return err
+		}
+		if f.Inlist.Width == 0 {
+			f.Inlist.Width = f.Width
+		}
+	}
+	return nil
+}
+
+var validReferenceUiComponents = map[UiComponent]bool{
+	"select":       true,
+	"autoComplete": true,
+}
+
+func (f *FieldReference) check(path string, value interface{}, vc *valueChecker) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		vc.add(path, "must be a string")
+		return nil
+	}
+	return s
+}
+
+// ListOptions return the candidate values, sorted by label.
+// namespace is the one of the edited object, used if the reference target is namespaced without explicit namespace.
+func (f *FieldReference) ListOptions(ctx context.Context, client k8s.Client, lookup WrapLookup, namespace string) ([]Option, error) {
+	apiVersion, kind, ns, selector, clusterScoped := f.ApiVersion, f.Kind, f.Namespace, f.Selector, false
+	if f.Wrap != "" {
+		target := lookup(f.Wrap)
+		if target == nil {
+			return nil, fmt.Errorf("referenced wrap '%s' does not exist", f.Wrap)
+		}
+		apiVersion, kind, selector, clusterScoped = target.Source.ApiVersion, target.Source.Kind, target.Source.Selector, target.Source.ClusterScoped
+		if ns == "" {
+			ns = target.Source.Namespace
+		}
+	}
+	if clusterScoped {
+		ns = ""
+	} else if ns == "" {
+		ns = namespace
+	}
+	list, err := client.ListResources(ctx, apiVersion, kind, ns, selector)
+	if err != nil {
+		return nil, err
+	}
+	options := make([]Option, 0, len(list.Items))
+	for _, item := range list.Items {
+		activation := resourceActivation(item.Object)
+		value, err := evalCel(f.OptionValue, activation)
+		if err != nil {
+			return nil, fmt.Errorf("optionValue: %w", err)
+		}
+		label, err := evalCel(f.OptionLabel, activation)
+		if err != nil {
+			return nil, fmt.Errorf("optionLabel: %w", err)
+		}
+		options = append(options, Option{Value: fmt.Sprintf("%v", value), Label: fmt.Sprintf("%v", label)})
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Label < options[j].Label
+	})
+	return options, nil
+}
+
+// FindReference return the reference field matching a path (i.e. 'groups' or 'package.repository').
+// Array items are transparent: An array of references is addressed by the array field path.
+func (w *Wrap) FindReference(path string) *FieldReference {
+	t := findType(w.Schema.Fields, path)
+	if t == nil {
+		return nil
+	}
+	return t.Reference
+}
+
+// CheckReferences ensure all submitted reference values (as returned by CheckValues()) match an existing object.
+// Return a *ValidationError if not.
+func (w *Wrap) CheckReferences(ctx context.Context, values map[string]interface{}, client k8s.Client, lookup WrapLookup, namespace string) error {
+	var errs fieldErrors
+	options := make(map[*FieldReference]map[string]bool)
+	var listErr error
+	visitValues(w.Schema.Fields, values, "", func(path string, t *Type, value interface{}) {
+		if t.Reference == nil || listErr != nil {
+			return
+		}
+		allowed, ok := options[t.Reference]
+		if !ok {
+			list, err := t.Reference.ListOptions(ctx, client, lookup, namespace)
+			if err != nil {
+				listErr = fmt.Errorf("unable to list options of '%s': %w", path, err)
+				return
+			}
+			allowed = make(map[string]bool, len(list))
+			for _, option := range list {
+				allowed[option.Value] = true
+			}
+			options[t.Reference] = allowed
+		}
+		if !allowed[fmt.Sprintf("%v", value)] {
+			errs.add(path, "'%v' does not exist", value)
+		}
+	})
+	if listErr != nil {
+		return listErr
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package wrap
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestReferences(t *testing.T) {
+	groups := parseTestWrap(t, "")
+	groups.Source.ApiVersion = "kubauth.kubotal.io/v1alpha1"
+	groups.Source.Kind = "Group"
+	lookup := func(name string) *Wrap {
+		if name == "groups" {
+			return groups
+		}
+		return nil
+	}
+	client := &fakeClient{objects: []*unstructured.Unstructured{
+		newTestObject("kubauth.kubotal.io/v1alpha1", "Group", "ns1", "admins", map[string]interface{}{"comment": "Administrators"}),
+		newTestObject("kubauth.kubotal.io/v1alpha1", "Group", "ns1", "devs", map[string]interface{}{"comment": "Developers"}),
+		newTestObject("kubauth.kubotal.io/v1alpha1", "Group", "ns2", "ops", map[string]interface{}{"comment": "Operators"}),
+	}}
+
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: groups
+      array:
+        uiComponent: multiSelect
+        item:
+          reference:
+            wrap: groups
+            optionLabel: ".spec.comment"
+`)
+	reference := w.FindReference("groups")
+	if reference == nil {
+		t.Fatal("Reference 'groups' not found")
+	}
+	options, err := reference.ListOptions(context.Background(), client, lookup, "ns1")
+	if err != nil {
+		t.Fatalf("ListOptions() failed: %v", err)
+	}
+	expected := []Option{{Value: "admins", Label: "Administrators"}, {Value: "devs", Label: "Developers"}}
+	if fmt.Sprint(options) != fmt.Sprint(expected) {
+		t.Errorf("Expected options %v, got %v", expected, options)
+	}
+
+	values, _, err := w.CheckValues(decodeValues(t, `{"groups": ["devs", "admins"]}`), nil)
+	checkErrorPaths(t, err)
+	checkErrorPaths(t, w.CheckReferences(context.Background(), values, client, lookup, "ns1"))
+
+	values, _, err = w.CheckValues(decodeValues(t, `{"groups": ["devs", "ops"]}`), nil)
+	checkErrorPaths(t, err)
+	checkErrorPaths(t, w.CheckReferences(context.Background(), values, client, lookup, "ns1"), "groups[1]")
+}
+
+func TestTargetNamespace(t *testing.T) {
+	w := parseTestWrap(t, "")
+	if ns := w.TargetNamespace("dev"); ns != "dev" {
+		t.Errorf("expected requested namespace, got %q", ns)
+	}
+	w.Source.Namespace = "fixed"
+	if ns := w.TargetNamespace("dev"); ns != "fixed" {
+		t.Errorf("expected source namespace, got %q", ns)
+	}
+	w.Source.ClusterScoped = true
+	if ns := w.TargetNamespace("dev"); ns != "" {
+		t.Errorf("expected no namespace for cluster scoped wrap, got %q", ns)
+	}
+}
+
+func TestGetObject(t *testing.T) {
+	w := parseTestWrap(t, "")
+	w.Source.Selector = map[string]string{"app": "users"}
+	selected := newTestObject("v1", "ConfigMap", "ns1", "u1", nil)
+	selected.SetLabels(map[string]string{"app": "users"})
+	other := newTestObject("v1", "ConfigMap", "ns1", "u2", nil)
+	client := &fakeClient{objects: []*unstructured.Unstructured{selected, other}}
+	if obj, err := w.GetObject(context.Background(), client, "ns1", "u1"); err != nil || obj.GetName() != "u1" {
+		t.Errorf("Expected selected object, got %v, %v", obj, err)
+	}
+	// An object of another wrap is not found
+	if _, err := w.GetObject(context.Background(), client, "ns1", "u2"); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package wrap
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DecorationKey is the top level property added to the objects returned to the front, to carry krapper computed data.
+const DecorationKey = "krapper"
+
+// FieldSecret is a write-only value (i.e. a password). It is never returned to the front, which only know if it is set or not.
+type FieldSecret struct {
+	// Location of the value in the object. Must be a plain path (i.e. '.spec.passwordHash').
+	// If Secret is defined, this is the location of the reference to the Secret, as rendered by the template.
+	Value Cel `yaml:"value,omitempty" json:"value,omitempty"`
+	// If defined, the value is stored in a separate k8s Secret, and the template is provided with {name, key} instead of the value.
+	Secret *struct {
+		Name      Cel    `yaml:"name" json:"name"`                               // Evaluated against the submitted values (i.e. "login + '-password'")
+		Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"` // Default to the namespace of the object
+		Key       string `yaml:"key,omitempty" json:"key,omitempty"`             // Default to the field name
+	} `yaml:"secret,omitempty" json:"secret,omitempty"`
+	// If defined, the submitted value is transformed before being provided to the template (i.e. 'bcrypt' to store only a hash)
+	Transform   Transform   `yaml:"transform,omitempty" json:"transform,omitempty"`
+	Cost        int         `yaml:"cost,omitempty" json:"cost,omitempty"` // bcrypt cost. Default to 10
+	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Width       int         `yaml:"width,omitempty" json:"width,omitempty"`
+	Inlist      *struct {
+		Hidden    bool      `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+		Header    string    `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
+		Alignment Alignment `yaml:"alignment,omitempty" json:"alignment,omitempty"`
+		Width     int       `yaml:"width,omitempty" json:"width,omitempty"`
+	} `yaml:"inlist,omitempty" json:"inlist,omitempty"`
+
+	path []string
+}
+
+func (f *FieldSecret) groom(defaultValueCel Cel, label string) error {
+	if f.Value == "" {
+		f.Value = defaultValueCel
+	}
+	var ok bool
+	f.path, ok = plainPath(f.Value)
+	if !ok {
+		return fmt.Errorf("value '%s' must be a plain path (i.e. '.spec.password')", f.Value)
+	}
+	if f.Secret != nil {
+		if f.Secret.Name == "" {
+			return fmt.Errorf("secret name is required")
+		}
+		err := validCel(f.Secret.Name)
+		if err != nil {
+			return fmt.Errorf("invalid secret name: %w", err)
+		}
+	}
+	switch f.Transform {
+	case "":
+		if f.Cost != 0 {
+			return fmt.Errorf("cost requires a 'bcrypt' transform")
+		}
+	case bcryptTransform:
+		if f.Cost == 0 {
+			f.Cost = bcrypt.DefaultCost
+		}
+		if f.Cost < bcrypt.MinCost || f.Cost > bcrypt.MaxCost {
+			return fmt.Errorf("invalid cost %d. Must be between %d and %d", f.Cost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("invalid transform: %s", f.Transform)
+	}
+	if f.Width == 0 {
+		f.Width = 30
+	}
+	if f.UiComponent == "" {
+		f.UiComponent = "password"
+	}
+	if !validSecretUiComponents[f.UiComponent] {
+		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
+	}
+	if f.Inlist != nil {
+		if f.Inlist.Header == "" {
+			f.Inlist.Header = label
+		}
+		if f.Inlist.Alignment == "" {
+			f.Inlist.Alignment = "left"
+		}
+		err := validAlignment(f.Inlist.Alignment)
+		if err != nil {
+			return err
+		}
+		if f.Inlist.Width == 0 {
+			f.Inlist.Width = f.Width
+		}
+	}
+	return nil
+}
+
+type Transform string
+
+const bcryptTransform Transform = "bcrypt"
+
+var validSecretUiComponents = map[UiComponent]bool{
+	"password": true,
+}
+
+func (f *FieldSecret) check(path string, value interface{}, vc *valueChecker) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		vc.add(path, "must be a string")
+		return nil
+	}
+	if f.Transform == bcryptTransform {
+		hash, err := bcrypt.GenerateFromPassword([]byte(s), f.Cost)
+		if err != nil {
+			vc.add(path, "%v", err)
+			return nil
+		}
+		return string(hash)
+	}
+	return s
+}
+
+var pathElementRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// plainPath split a path expression such as '.spec.password' or 'resource.spec.password'. Return false if exp is not such a path.
+func plainPath(exp Cel) ([]string, bool) {
+	s := strings.TrimPrefix(strings.TrimPrefix(string(exp), "resource."), ".")
+	path := strings.Split(s, ".")
+	for _, elem := range path {
+		if !pathElementRegex.MatchString(elem) {
+			return nil, false
+		}
+	}
+	return path, true
+}
+
+// visitSecrets call fn for each secret field, with its path in the submitted values. Secrets are not allowed in arrays.
+func visitSecrets(fields []Field, path string, fn func(path string, field *Field)) {
+	for idx := range fields {
+		field := &fields[idx]
+		fieldPath := joinFieldPath(path, field.Name)
+		switch {
+		case field.Type.Secret != nil:
+			fn(fieldPath, field)
+		case field.Type.Object != nil:
+			visitSecrets(field.Type.Object.Fields, fieldPath, fn)
+		}
+	}
+}
+
+// MaskSecrets remove secret values from an object, and report their state ('set' or 'unset') under the
+// DecorationKey property, by field path.
+func (w *Wrap) MaskSecrets(obj *unstructured.Unstructured) {
+	states := make(map[string]interface{})
+	visitSecrets(w.Schema.Fields, "", func(path string, field *Field) {
+		secret := field.Type.Secret
+		if _, set := secretValue(obj, secret.path); set {
+			states[path] = "set"
+		} else {
+			states[path] = "unset"
+		}
+		if secret.Secret == nil {
+			unstructured.RemoveNestedField(obj.Object, secret.path...)
+		}
+	})
+	if len(states) == 0 {
+		return
+	}
+	decoration, ok := obj.Object[DecorationKey].(map[string]interface{})
+	if !ok {
+		decoration = make(map[string]interface{})
+		obj.Object[DecorationKey] = decoration
+	}
+	decoration["secrets"] = states
+}
+
+// SecretChange is the modification of a secret value between two versions of an object, at its dotted path in the object.
+// Before is 'set' or 'unset', After is 'set', 'unset' or 'changed'. The values themselves are never reported.
+type SecretChange struct {
+	Path   string
+	Before string
+	After  string
+}
+
+// SecretChanges return the secret values modified between before and after (any of them may be nil), as MaskSecrets
+// would hide them. Values stored in a separate Secret are not compared, as the object only holds their reference.
+func (w *Wrap) SecretChanges(before *unstructured.Unstructured, after *unstructured.Unstructured) []SecretChange {
+	changes := make([]SecretChange, 0)
+	visitSecrets(w.Schema.Fields, "", func(path string, field *Field) {
+		secret := field.Type.Secret
+		if secret.Secret != nil {
+			return
+		}
+		b, bSet := secretValue(before, secret.path)
+		a, aSet := secretValue(after, secret.path)
+		change := SecretChange{Path: strings.Join(secret.path, "."), Before: "unset", After: "unset"}
+		switch {
+		case !bSet && !aSet:
+			return
+		case bSet && aSet:
+			if reflect.DeepEqual(b, a) {
+				return
+			}
+			change.Before, change.After = "set", "changed"
+		case bSet:
+			change.Before = "set"
+		default:
+			change.After = "set"
+		}
+		changes = append(changes, change)
+	})
+	return changes
+}
+
+// secretValue return the value at path in obj, and whether it is set
+func secretValue(obj *unstructured.Unstructured, path []string) (interface{}, bool) {
+	if obj == nil {
+		return nil, false
+	}
+	value, found, _ := unstructured.NestedFieldNoCopy(obj.Object, path...)
+	return value, found && !isEmptyValue(value)
+}
+
+// PrepareSecrets build the k8s Secrets holding the submitted values of the secret fields which must be stored separately.
+// In values (as returned by CheckValues()), such values are replaced by a {name, key} reference, to be used by the template.
+// namespace is the one of the object.
+func (w *Wrap) PrepareSecrets(values map[string]interface{}, namespace string) ([]*unstructured.Unstructured, error) {
+	secrets := make([]*unstructured.Unstructured, 0)
+	var err error
+	visitSecrets(w.Schema.Fields, "", func(path string, field *Field) {
+		secret := field.Type.Secret
+		if secret.Secret == nil || err != nil {
+			return
+		}
+		parent, name := valueParent(values, path)
+		value, ok := parent[name].(string)
+		if !ok {
+			return
+		}
+		var ref ObjectRef
+		ref, err = secret.secretRef(path, values, namespace)
+		if err != nil {
+			return
+		}
+		key := secret.Secret.Key
+		if key == "" {
+			key = field.Name
+		}
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"type":       "Opaque",
+			"stringData": map[string]interface{}{key: value},
+		}}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("Secret")
+		obj.SetNamespace(ref.Namespace)
+		obj.SetName(ref.Name)
+		secrets = append(secrets, obj)
+		parent[name] = map[string]interface{}{"name": obj.GetName(), "key": key}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// secretRef return the Secret storing the value of the field at path. namespace is the one of the object.
+func (f *FieldSecret) secretRef(path string, values map[string]interface{}, namespace string) (ObjectRef, error) {
+	name, err := evalCel(f.Secret.Name, values)
+	if err != nil {
+		return ObjectRef{}, fmt.Errorf("field '%s': secret name: %w", path, err)
+	}
+	ns := f.Secret.Namespace
+	if ns == "" {
+		ns = namespace
+	}
+	if ns == "" {
+		return ObjectRef{}, fmt.Errorf("field '%s': no namespace for the secret", path)
+	}
+	return ObjectRef{ApiVersion: "v1", Kind: "Secret", Namespace: ns, Name: fmt.Sprintf("%v", name)}, nil
+}
+
+// KeepSecrets preserve, in the rendered object, the secret values which were not resubmitted.
+// existing is the current version of the object, or nil on creation. In such case, required secrets must be provided.
+// values and inactive are the ones returned by CheckValues(): inactive secrets are neither required nor kept.
+// Return the Secrets still holding a kept value (to be kept in the set), and a *ValidationError on missing required secret.
+func (w *Wrap) KeepSecrets(obj *unstructured.Unstructured, existing *unstructured.Unstructured, values map[string]interface{}, inactive []string) ([]ObjectRef, error) {
+	var errs fieldErrors
+	kept := make([]ObjectRef, 0)
+	var err error
+	visitSecrets(w.Schema.Fields, "", func(path string, field *Field) {
+		parent, name := valueParent(values, path)
+		if _, ok := parent[name]; ok || err != nil || isInactive(inactive, path) {
+			return
+		}
+		var previous interface{}
+		found := false
+		if existing != nil {
+			previous, found, _ = unstructured.NestedFieldCopy(existing.Object, field.Type.Secret.path...)
+		}
+		if !found || isEmptyValue(previous) {
+			if field.Required {
+				errs.add(path, "%s is required", field.Label)
+			}
+			return
+		}
+		setValue(obj.Object, previous, field.Type.Secret.path)
+		if field.Type.Secret.Secret != nil {
+			var ref ObjectRef
+			ref, err = field.Type.Secret.secretRef(path, values, obj.GetNamespace())
+			kept = append(kept, ref)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return kept, nil
+}
+
+// setValue set a value in an object, creating intermediate maps if missing or null (i.e. an empty 'spec:' rendered by a template)
+func setValue(obj map[string]interface{}, value interface{}, path []string) {
+	m := obj
+	for _, elem := range path[:len(path)-1] {
+		child, ok := m[elem].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[elem] = child
+		}
+		m = child
+	}
+	m[path[len(path)-1]] = value
+}
+
+// valueParent return the map holding the value at path (i.e. 'package.token'), and its key in this map.
+// The returned map is empty if an intermediate object is missing.
+func valueParent(values map[string]interface{}, path string) (map[string]interface{}, string) {
+	elems := strings.Split(path, ".")
+	parent := values
+	for _, elem := range elems[:len(elems)-1] {
+		child, ok := parent[elem].(map[string]interface{})
+		if !ok {
+			return map[string]interface{}{}, elems[len(elems)-1]
+		}
+		parent = child
+	}
+	return parent, elems[len(elems)-1]
+}
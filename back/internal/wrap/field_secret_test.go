@@ -0,0 +1,245 @@
+package wrap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSecret(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: name
+    - name: password
+      required: true
+      secret:
+        value: ".data.password"
+    - name: token
+      secret:
+        value: ".data.tokenSecret"
+        secret:
+          name: "name + '-token'"
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .Fields.name }}
+    namespace: ns1
+  data:
+    {{- with .Fields.password }}
+    password: {{ . }}
+    {{- end }}
+    {{- with .Fields.token }}
+    tokenSecret: {{ .name }}/{{ .key }}
+    {{- end }}
+`)
+	render := func(payload string, existing map[string]interface{}) (map[string]interface{}, []map[string]interface{}, error) {
+		values, inactive, err := w.CheckValues(decodeValues(t, payload), nil)
+		checkErrorPaths(t, err)
+		secrets, err := w.PrepareSecrets(values, "ns1")
+		if err != nil {
+			t.Fatalf("PrepareSecrets() failed: %v", err)
+		}
+		set, err := w.Render(&TemplateData{Fields: values, Metadata: map[string]interface{}{}})
+		if err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		obj := set.Primary
+		previous := &unstructured.Unstructured{Object: existing}
+		if existing == nil {
+			previous = nil
+		}
+		_, err = w.KeepSecrets(obj, previous, values, inactive)
+		result := make([]map[string]interface{}, 0, len(secrets))
+		for _, secret := range secrets {
+			result = append(result, secret.Object)
+		}
+		return obj.Object, result, err
+	}
+
+	// Required on creation only
+	_, _, err := render(`{"name": "cm1"}`, nil)
+	checkErrorPaths(t, err, "password")
+
+	obj, secrets, err := render(`{"name": "cm1", "password": "p1", "token": "t1"}`, nil)
+	checkErrorPaths(t, err)
+	if len(secrets) != 1 || secrets[0]["stringData"].(map[string]interface{})["token"] != "t1" {
+		t.Fatalf("Unexpected secrets: %v", secrets)
+	}
+	if secrets[0]["metadata"].(map[string]interface{})["name"] != "cm1-token" || secrets[0]["metadata"].(map[string]interface{})["namespace"] != "ns1" {
+		t.Errorf("Unexpected secret metadata: %v", secrets[0]["metadata"])
+	}
+	data := obj["data"].(map[string]interface{})
+	if data["password"] != "p1" || data["tokenSecret"] != "cm1-token/token" {
+		t.Errorf("Unexpected data: %v", data)
+	}
+
+	// Not resubmitted values are kept
+	updated, secrets, err := render(`{"name": "cm1"}`, obj)
+	checkErrorPaths(t, err)
+	if len(secrets) != 0 {
+		t.Errorf("Unexpected secrets: %v", secrets)
+	}
+	data = updated["data"].(map[string]interface{})
+	if data["password"] != "p1" || data["tokenSecret"] != "cm1-token/token" {
+		t.Errorf("Secrets not kept: %v", data)
+	}
+
+	masked := &unstructured.Unstructured{Object: updated}
+	masked.Object["data"].(map[string]interface{})["tokenSecret"] = ""
+	w.MaskSecrets(masked)
+	if _, ok := masked.Object["data"].(map[string]interface{})["password"]; ok {
+		t.Errorf("Secret value not removed: %v", masked.Object)
+	}
+	states := masked.Object[DecorationKey].(map[string]interface{})["secrets"].(map[string]interface{})
+	if states["password"] != "set" || states["token"] != "unset" {
+		t.Errorf("Unexpected secret states: %v", states)
+	}
+}
+
+func TestSecretCondition(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: useLocal
+      boolean: {}
+    - name: password
+      required: true
+      condition: "useLocal"
+      secret:
+        value: ".data.password"
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm1
+    namespace: ns1
+  data:
+    {{- with .Fields.password }}
+    password: {{ . }}
+    {{- end }}
+`)
+	keep := func(payload string, existing *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		values, inactive, err := w.CheckValues(decodeValues(t, payload), existing)
+		checkErrorPaths(t, err)
+		set, err := w.Render(&TemplateData{Fields: values, Metadata: map[string]interface{}{}})
+		if err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		_, err = w.KeepSecrets(set.Primary, existing, values, inactive)
+		return set.Primary, err
+	}
+	// An inactive secret is not required
+	_, err := keep(`{"useLocal": false}`, nil)
+	checkErrorPaths(t, err)
+	_, err = keep(`{"useLocal": true}`, nil)
+	checkErrorPaths(t, err, "password")
+
+	// Nor kept on update
+	existing, err := keep(`{"useLocal": true, "password": "p1"}`, nil)
+	checkErrorPaths(t, err)
+	obj, err := keep(`{"useLocal": false}`, existing)
+	checkErrorPaths(t, err)
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "data", "password"); found {
+		t.Errorf("Inactive secret kept: %v", obj.Object["data"])
+	}
+	obj, err = keep(`{"useLocal": true}`, existing)
+	checkErrorPaths(t, err)
+	if password, _, _ := unstructured.NestedString(obj.Object, "data", "password"); password != "p1" {
+		t.Errorf("Active secret not kept: %v", obj.Object["data"])
+	}
+}
+
+func TestSecretChanges(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: password
+      secret:
+        value: ".data.password"
+    - name: apiKey
+      secret:
+        value: ".data.apiKey"
+    - name: token
+      secret:
+        value: ".data.tokenSecret"
+        secret:
+          name: "'token'"
+`)
+	object := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"data": data}}
+	}
+	before := object(map[string]interface{}{"password": "p1", "apiKey": "k1", "tokenSecret": "t1/token"})
+	after := object(map[string]interface{}{"password": "p2", "apiKey": "", "tokenSecret": "t2/token"})
+	expected := []SecretChange{
+		{Path: "data.password", Before: "set", After: "changed"},
+		{Path: "data.apiKey", Before: "set", After: "unset"},
+	}
+	if changes := w.SecretChanges(before, after); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected changes: %v", changes)
+	}
+	expected = []SecretChange{{Path: "data.password", Before: "unset", After: "set"}}
+	if changes := w.SecretChanges(nil, object(map[string]interface{}{"password": "p1"})); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected changes on creation: %v", changes)
+	}
+	if changes := w.SecretChanges(before, before.DeepCopy()); len(changes) != 0 {
+		t.Errorf("Unexpected changes on same values: %v", changes)
+	}
+}
+
+func TestSecretPath(t *testing.T) {
+	_, err := Parse([]byte(testWrapHeader+`
+schema:
+  fields:
+    - name: password
+      secret:
+        value: "spec.users[0].password"
+`), "test")
+	if err == nil {
+		t.Error("Expected an error on a non plain path")
+	}
+}
+
+func TestSecretBcrypt(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: passwordHash
+      validation:
+        test: "size(self) >= 6"
+        message: "At least 6 characters"
+      secret:
+        transform: bcrypt
+        cost: 4
+`)
+	values, _, err := w.CheckValues(decodeValues(t, `{"passwordHash": "secret1"}`), nil)
+	checkErrorPaths(t, err)
+	hash := values["passwordHash"].(string)
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret1")) != nil {
+		t.Errorf("Unexpected hash: %s", hash)
+	}
+	if cost, _ := bcrypt.Cost([]byte(hash)); cost != 4 {
+		t.Errorf("Expected cost 4, got %d", cost)
+	}
+	_, _, err = w.CheckValues(decodeValues(t, `{"passwordHash": "`+strings.Repeat("x", 80)+`"}`), nil)
+	checkErrorPaths(t, err, "passwordHash")
+	// The validation applies to the plaintext, not to the hash
+	_, _, err = w.CheckValues(decodeValues(t, `{"passwordHash": "abc"}`), nil)
+	checkErrorPaths(t, err, "passwordHash")
+
+	_, err = Parse([]byte(testWrapHeader+`
+schema:
+  fields:
+    - name: passwordHash
+      secret:
+        transform: bcrypt
+        cost: 50
+`), "test")
+	if err == nil {
+		t.Error("Expected an error on invalid cost")
+	}
+}
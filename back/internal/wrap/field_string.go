@@ -1,14 +1,21 @@
 package wrap
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
 
 type FieldString struct {
-	Default     string      `yaml:"default,omitempty" json:"default,omitempty"`
-	Enum        []string    `yaml:"enum,omitempty" json:"enum,omitempty"`
-	Value       Cel         `yaml:"value,omitempty" json:"value,omitempty"`
-	UiComponent UiComponent `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
-	Width       int         `yaml:"width,omitempty" json:"width,omitempty"`
-	Height      int         `yaml:"height,omitempty" json:"height,omitempty"`
+	Default     string             `yaml:"default,omitempty" json:"default,omitempty"`
+	Enum        []EnumItem[string] `yaml:"enum,omitempty" json:"enum,omitempty"`
+	MinLength   int                `yaml:"minLength,omitempty" json:"minLength,omitempty"`
+	MaxLength   int                `yaml:"maxLength,omitempty" json:"maxLength,omitempty"` // No limit if 0
+	Pattern     string             `yaml:"pattern,omitempty" json:"pattern,omitempty"`     // Regex the value must match
+	Value       Cel                `yaml:"value,omitempty" json:"value,omitempty"`
+	UiComponent UiComponent        `yaml:"uiComponent,omitempty" json:"uiComponent,omitempty"`
+	Width       int                `yaml:"width,omitempty" json:"width,omitempty"`
+	Height      int                `yaml:"height,omitempty" json:"height,omitempty"`
 	Inlist      *struct {
 		Hidden      bool        `yaml:"hidden,omitempty" json:"hidden,omitempty"`
 		Header      string      `yaml:"header,omitempty" json:"header,omitempty"` // Default to label
@@ -18,6 +25,8 @@ type FieldString struct {
 		Width       int         `yaml:"width,omitempty" json:"width,omitempty"`
 		Height      int         `yaml:"height,omitempty" json:"height,omitempty"`
 	} `yaml:"inlist,omitempty" json:"inlist,omitempty"`
+
+	patternRegex *regexp.Regexp
 }
 
 func (f *FieldString) groom(defaultValueCel Cel, label string) error {
@@ -34,8 +43,34 @@ func (f *FieldString) groom(defaultValueCel Cel, label string) error {
 	if f.Height == 0 {
 		f.Height = 1
 	}
+	err = groomEnum(f.Enum)
+	if err != nil {
+		return err
+	}
+	if f.Default != "" && len(f.Enum) > 0 && !enumContains(f.Enum, f.Default) {
+		return fmt.Errorf("default value '%s' is not in enum", f.Default)
+	}
+	if f.MinLength < 0 || f.MaxLength < 0 {
+		return fmt.Errorf("minLength and maxLength must not be negative")
+	}
+	if f.MaxLength != 0 && f.MaxLength < f.MinLength {
+		return fmt.Errorf("maxLength '%d' is lower than minLength '%d'", f.MaxLength, f.MinLength)
+	}
+	if f.Pattern != "" {
+		f.patternRegex, err = regexp.Compile(f.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	if f.Default != "" {
+		if msg := f.checkConstraints(f.Default); msg != "" {
+			return fmt.Errorf("default value: %s", msg)
+		}
+	}
 	if f.UiComponent == "" {
-		if f.Height == 1 {
+		if len(f.Enum) > 0 {
+			f.UiComponent = "select"
+		} else if f.Height == 1 {
 			f.UiComponent = "input"
 		} else {
 			f.UiComponent = "textarea"
@@ -44,6 +79,10 @@ func (f *FieldString) groom(defaultValueCel Cel, label string) error {
 	if !validStringUiComponents[f.UiComponent] {
 		return fmt.Errorf("invalid UiComponent: %s", f.UiComponent)
 	}
+	err = validEnumUiComponent(f.UiComponent, len(f.Enum))
+	if err != nil {
+		return err
+	}
 	if f.Inlist != nil {
 		if f.Inlist.Header == "" {
 			f.Inlist.Header = label
@@ -81,4 +120,36 @@ func (f *FieldString) groom(defaultValueCel Cel, label string) error {
 var validStringUiComponents = map[UiComponent]bool{
 	"input":    true,
 	"textarea": true,
+	"select":   true,
+	"radio":    true,
+}
+
+func (f *FieldString) check(path string, value interface{}, vc *valueChecker) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		vc.add(path, "must be a string")
+		return nil
+	}
+	if len(f.Enum) > 0 && !enumContains(f.Enum, s) {
+		vc.add(path, "'%s' is not an allowed value", s)
+	}
+	if msg := f.checkConstraints(s); msg != "" {
+		vc.add(path, "%s", msg)
+	}
+	return s
+}
+
+// checkConstraints return an error message, or "" if the value match length and pattern constraints
+func (f *FieldString) checkConstraints(s string) string {
+	length := utf8.RuneCountInString(s)
+	if length < f.MinLength {
+		return fmt.Sprintf("must be at least %d characters long", f.MinLength)
+	}
+	if f.MaxLength != 0 && length > f.MaxLength {
+		return fmt.Sprintf("must be at most %d characters long", f.MaxLength)
+	}
+	if f.patternRegex != nil && !f.patternRegex.MatchString(s) {
+		return fmt.Sprintf("must match '%s'", f.Pattern)
+	}
+	return ""
 }
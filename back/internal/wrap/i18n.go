@@ -0,0 +1,204 @@
+package wrap
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FallbackLocale is used for the localized texts when none of the requested locales is provided.
+// If this one is also missing, the first locale in lexical order is used.
+var FallbackLocale = "en"
+
+// localizableKeys are the properties which may be defined as a map by locale (i.e. 'label: {en: Name, fr: Nom}')
+var localizableKeys = map[string]bool{
+	"label":        true,
+	"tooltip":      true,
+	"description":  true,
+	"message":      true,
+	"header":       true,
+	"confirmation": true,
+}
+
+// localized hold the source of a definition, to build its variants for other locales.
+// It is nil if the definition has no localized texts.
+type localized[T any] struct {
+	source    *yaml.Node
+	available map[string]string // Locales found in the source, by lower case form
+	mu        sync.Mutex
+	variants  map[string]*T // By matched locales list
+}
+
+func newLocalized[T any](source *yaml.Node) *localized[T] {
+	available := make(map[string]string)
+	visitLocaleMaps(source, func(m *yaml.Node) {
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			available[strings.ToLower(m.Content[i].Value)] = m.Content[i].Value
+		}
+	})
+	if len(available) == 0 {
+		return nil
+	}
+	return &localized[T]{
+		source:    source,
+		available: available,
+		variants:  make(map[string]*T),
+	}
+}
+
+// get return the variant for the requested locales, by decreasing preference. dflt is the variant built for the FallbackLocale.
+// Requested locales are first reduced to the available ones, so the number of variants is bounded.
+func (l *localized[T]) get(dflt *T, locales []string, build func(source *yaml.Node, locales []string) (*T, error)) *T {
+	if l == nil {
+		return dflt
+	}
+	matched := l.match(locales)
+	if len(matched) == 0 {
+		return dflt
+	}
+	key := strings.Join(matched, ",")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.variants[key]; ok {
+		return v
+	}
+	v, err := build(l.source, matched)
+	if err != nil {
+		// Should not occur, as the default variant was successfully built from the same source
+		v = dflt
+	}
+	l.variants[key] = v
+	return v
+}
+
+// match return the available locales matching the requested ones. A requested 'fr-CA' match 'fr-CA' or, if missing, 'fr'.
+func (l *localized[T]) match(locales []string) []string {
+	matched := make([]string, 0, len(locales))
+	for _, locale := range locales {
+		locale = strings.ToLower(locale)
+		found, ok := l.available[locale]
+		if !ok {
+			base, _, _ := strings.Cut(locale, "-")
+			if found, ok = l.available[base]; !ok {
+				continue
+			}
+		}
+		if !slices.Contains(matched, found) {
+			matched = append(matched, found)
+		}
+	}
+	return matched
+}
+
+// decodeLocalized decode a definition node, with locale maps replaced by the text for the locales
+func decodeLocalized(node *yaml.Node, locales []string, target interface{}) error {
+	node = copyNode(node)
+	localizeNode(node, locales)
+	// The node is decoded as is, so that errors report the line in the source file
+	if err := checkKnownFields(node, reflect.TypeOf(target)); err != nil {
+		return err
+	}
+	return node.Decode(target)
+}
+
+// localizeNode replace, in place, each locale map by the text of the first available locale
+func localizeNode(node *yaml.Node, locales []string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			localizeNode(child, locales)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if isLocaleMap(node.Content[i].Value, value) {
+				node.Content[i+1] = selectLocale(value, locales)
+				continue
+			}
+			localizeNode(value, locales)
+		}
+	}
+}
+
+// selectLocale return the text for the first of locales, then for the FallbackLocale, then for the lowest locale.
+func selectLocale(m *yaml.Node, locales []string) *yaml.Node {
+	for _, locale := range append(slices.Clip(locales), FallbackLocale) {
+		if text := mappingValue(m, locale); text != nil {
+			return text
+		}
+	}
+	lowest := -1
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if lowest < 0 || m.Content[i].Value < m.Content[lowest].Value {
+			lowest = i
+		}
+	}
+	return m.Content[lowest+1]
+}
+
+func isLocaleMap(key string, value *yaml.Node) bool {
+	if !localizableKeys[key] || value.Kind != yaml.MappingNode || len(value.Content) == 0 {
+		return false
+	}
+	for i := 1; i < len(value.Content); i += 2 {
+		if value.Content[i].Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
+
+func visitLocaleMaps(node *yaml.Node, fn func(m *yaml.Node)) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			visitLocaleMaps(child, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if isLocaleMap(node.Content[i].Value, node.Content[i+1]) {
+				fn(node.Content[i+1])
+				continue
+			}
+			visitLocaleMaps(node.Content[i+1], fn)
+		}
+	}
+}
+
+// Localize return the variant of the wrap with texts in the first available of the locales (by decreasing preference).
+// Return the wrap itself if it has no localized texts, or if none of the locales is available.
+func (w *Wrap) Localize(locales []string) *Wrap {
+	return w.i18n.get(w, locales, decodeWrap)
+}
+
+// Localize return the variant of the menu with labels in the first available of the locales (by decreasing preference).
+func (m *Menu) Localize(locales []string) *Menu {
+	return m.i18n.get(m, locales, decodeMenu)
+}
+
+// decodeWrap build a localized variant of a wrap
+func decodeWrap(source *yaml.Node, locales []string) (*Wrap, error) {
+	var w Wrap
+	if err := decodeLocalized(source, locales, &w); err != nil {
+		return nil, err
+	}
+	if err := w.Groom(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// decodeMenu build a localized variant of a menu
+func decodeMenu(source *yaml.Node, locales []string) (*Menu, error) {
+	var m Menu
+	if err := decodeLocalized(source, locales, &m); err != nil {
+		return nil, err
+	}
+	if err := m.Groom(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,112 @@
+package wrap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLocalize(t *testing.T) {
+	w := parseTestWrap(t, `
+label:
+  en: Users
+  fr: Utilisateurs
+description:
+  fr: Comptes utilisateur
+  de: Benutzerkonten
+schema:
+  fields:
+    - name: login
+      label: {en: Login, fr: Identifiant}
+      tooltip: {en: Unique user id, fr: Identifiant unique}
+      required: true
+      validation:
+        test: "size(login) > 2"
+        message: {en: Too short, fr: Trop court}
+      string:
+        inlist:
+          header: {en: User, fr: Utilisateur}
+    - name: comment
+`)
+	// Default variant uses the fallback locale, then the lowest one
+	if w.Label != "Users" || w.Description != "Benutzerkonten" || w.Schema.Fields[0].Tooltip != "Unique user id" {
+		t.Errorf("Unexpected default texts: %q, %q, %q", w.Label, w.Description, w.Schema.Fields[0].Tooltip)
+	}
+	fr := w.Localize([]string{"fr-CA", "en"})
+	if fr.Label != "Utilisateurs" || fr.Description != "Comptes utilisateur" {
+		t.Errorf("Unexpected fr texts: %q, %q", fr.Label, fr.Description)
+	}
+	login := fr.Schema.Fields[0]
+	if login.Label != "Identifiant" || login.Tooltip != "Identifiant unique" || login.Type.String.Inlist.Header != "Utilisateur" {
+		t.Errorf("Unexpected fr field texts: %q, %q, %q", login.Label, login.Tooltip, login.Type.String.Inlist.Header)
+	}
+	if fr.Schema.Fields[1].Label != "Comment" {
+		t.Errorf("Expected non localized label to be preserved, got %q", fr.Schema.Fields[1].Label)
+	}
+	if again := w.Localize([]string{"FR-ca", "en-GB"}); again != fr {
+		t.Errorf("Expected variant to be cached")
+	}
+	if w.Localize([]string{"it"}) != w || w.Localize(nil) != w {
+		t.Errorf("Expected default variant for unavailable locales")
+	}
+	// Description missing in 'en': Fall back to the next requested locale
+	if got := w.Localize([]string{"en", "fr"}).Description; got != "Comptes utilisateur" {
+		t.Errorf("Expected next requested locale for description, got %q", got)
+	}
+
+	_, _, err := fr.CheckValues(decodeValues(t, `{"login": "ab"}`), nil)
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) || len(validationError.Errors) != 1 || validationError.Errors[0].Message != "Trop court" {
+		t.Errorf("Expected localized validation message, got %v", err)
+	}
+	_, _, err = fr.CheckValues(decodeValues(t, `{}`), nil)
+	if err == nil || err.Error() != "invalid values: login: Identifiant is required" {
+		t.Errorf("Expected localized required message, got %v", err)
+	}
+}
+
+func TestLocalizeFallbackLocale(t *testing.T) {
+	saved := FallbackLocale
+	FallbackLocale = "fr"
+	defer func() { FallbackLocale = saved }()
+	w := parseTestWrap(t, `
+label: {en: Users, fr: Utilisateurs}
+`)
+	if w.Label != "Utilisateurs" {
+		t.Errorf("Expected fallback locale label, got %q", w.Label)
+	}
+	if got := w.Localize([]string{"EN-us"}).Label; got != "Users" {
+		t.Errorf("Expected 'Users', got %q", got)
+	}
+}
+
+func TestDecodeErrorLines(t *testing.T) {
+	_, err := Parse([]byte(testWrapHeader+`schema:
+  fields:
+    - name: login
+      label: {en: Login, fr: Identifiant}
+      tooltip: {en: Unique}
+      unknown: x
+    - name: size
+      integer:
+        min: abc
+`), "test")
+	if err == nil {
+		t.Fatal("Expected decoding errors")
+	}
+	if !strings.Contains(err.Error(), "line 15: field unknown not found") {
+		t.Errorf("Unknown field not reported at its line: %v", err)
+	}
+
+	_, err = Parse([]byte(testWrapHeader+`schema:
+  fields:
+    - name: login
+      label: {en: Login, fr: Identifiant}
+    - name: size
+      integer:
+        min: abc
+`), "test")
+	if err == nil || !strings.Contains(err.Error(), "line 16:") {
+		t.Errorf("Type error not reported at its line: %v", err)
+	}
+}
@@ -1,10 +1,14 @@
 package wrap
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,39 +18,178 @@ type header struct {
 	Kind       string `yaml:"kind" json:"kind"`
 }
 
+// Document is the content of a krapper definition file. Only one member is set.
+type Document struct {
+	Wrap      *Wrap
+	Menu      *Menu
+	Fragment  *Fragment
+	Composite *Composite // A wrap using 'extends' or 'include', to be resolved against other documents
+
+	node     *yaml.Node // Wrap and Fragment source, to build composite wraps
+	location string
+}
+
 // Load a wrap file. Return nil, nil if file s not a wrap one.
 func Load(fName string) (*Wrap, error) {
+	doc, err := LoadDocument(fName)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc.Wrap, nil
+}
+
+// Parse a wrap definition. location is only used in error messages.
+// Return nil, nil if data is not a wrap one.
+func Parse(data []byte, location string) (*Wrap, error) {
+	doc, err := ParseDocument(data, location)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc.Wrap, nil
+}
+
+// LoadDocument load a krapper definition file. Return nil, nil if file is not a krapper one.
+func LoadDocument(fName string) (*Document, error) {
 
 	filename, err := filepath.Abs(fName)
 	if err != nil {
 		log.Fatalf("Error getting absolute path of yaml file: %v", err)
 	}
 
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error opening file %s: %v", filename, err)
 	}
-	defer func() { _ = file.Close() }()
+	return ParseDocument(data, filename)
+}
 
+// ParseDocument parse a krapper definition (Wrap, Menu or Fragment). location is only used in error messages.
+// Return nil, nil if data is not a krapper one.
+func ParseDocument(data []byte, location string) (*Document, error) {
 	var h header
-	err = yaml.NewDecoder(file).Decode(&h)
-	if err != nil || h.ApiVersion != "krapper.kubotal.io/v1alpha1" || h.Kind != "Wrap" {
-		return nil, nil // Non-wrap file
+	err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&h)
+	if err != nil || h.ApiVersion != "krapper.kubotal.io/v1alpha1" {
+		return nil, nil // Non-krapper file
 	}
-	_, err = file.Seek(0, 0)
-	if err != nil {
-		return nil, fmt.Errorf("error seeking file %s: %v", filename, err)
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", location, err)
 	}
-	var w Wrap
-	decoder := yaml.NewDecoder(file)
-	decoder.KnownFields(true)
-	if err := decoder.Decode(&w); err != nil {
-		return nil, fmt.Errorf("error decoding file %s: %v", filename, err)
+	switch h.Kind {
+	case "Wrap":
+		if composite := newComposite(&node); composite != nil {
+			return &Document{Composite: composite, node: &node, location: location}, nil
+		}
+		var w Wrap
+		if err := decodeLocalized(&node, nil, &w); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", location, err)
+		}
+		err = w.Groom()
+		if err != nil {
+			return nil, err
+		}
+		w.i18n = newLocalized[Wrap](&node)
+		return &Document{Wrap: &w, node: &node, location: location}, nil
+	case "Fragment":
+		var f Fragment
+		if err := decodeLocalized(&node, nil, &f); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", location, err)
+		}
+		err = f.Groom()
+		if err != nil {
+			return nil, err
+		}
+		return &Document{Fragment: &f, node: &node, location: location}, nil
+	case "Menu":
+		var m Menu
+		if err := decodeLocalized(&node, nil, &m); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", location, err)
+		}
+		err = m.Groom()
+		if err != nil {
+			return nil, err
+		}
+		m.i18n = newLocalized[Menu](&node)
+		return &Document{Menu: &m}, nil
+	default:
+		return nil, nil // Non-krapper file
 	}
+}
 
-	err = w.Groom()
-	if err != nil {
-		return nil, err
+var yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkKnownFields report the mapping keys matching no member of the target type, as a decoder with KnownFields(true),
+// which is not available for yaml.Node.Decode(). Types with a custom unmarshaler are not checked.
+func checkKnownFields(node *yaml.Node, t reflect.Type) error {
+	var errs []string
+	walkKnownFields(node, t, &errs)
+	if len(errs) > 0 {
+		return &yaml.TypeError{Errors: errs}
+	}
+	return nil
+}
+
+func walkKnownFields(node *yaml.Node, t reflect.Type, errs *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Implements(yamlUnmarshalerType) || reflect.PointerTo(t).Implements(yamlUnmarshalerType) {
+		return
+	}
+	if node.Kind == yaml.DocumentNode {
+		for _, child := range node.Content {
+			walkKnownFields(child, t, errs)
+		}
+		return
+	}
+	// Type mismatches are reported by Decode()
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		members := yamlMembers(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			member, ok := members[key.Value]
+			if !ok {
+				*errs = append(*errs, fmt.Sprintf("line %d: field %s not found in type %s", key.Line, key.Value, t))
+				continue
+			}
+			walkKnownFields(node.Content[i+1], member, errs)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yaml.SequenceNode:
+		for _, child := range node.Content {
+			walkKnownFields(child, t.Elem(), errs)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkKnownFields(node.Content[i+1], t.Elem(), errs)
+		}
+	}
+}
+
+// yamlMembers return the types of the struct members by yaml key, including the inlined ones
+func yamlMembers(t reflect.Type) map[string]reflect.Type {
+	members := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if slices.Contains(strings.Split(options, ","), "inline") {
+			inlined := field.Type
+			if inlined.Kind() == reflect.Pointer {
+				inlined = inlined.Elem()
+			}
+			for k, v := range yamlMembers(inlined) {
+				members[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		members[name] = field.Type
 	}
-	return &w, nil
+	return members
 }
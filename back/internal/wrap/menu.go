@@ -0,0 +1,74 @@
+package wrap
+
+import (
+	"fmt"
+)
+
+// Menu define a hierarchical organization of the wraps.
+// All loaded menus are merged, by increasing order.
+type Menu struct {
+	// required
+	ApiVersion string `yaml:"apiVersion" json:"apiVersion"`
+	// Always 'Menu'
+	Kind string `yaml:"kind" json:"kind"`
+	// Required
+	Name string `yaml:"name" json:"name"`
+	// Optional. Merging order of the menus
+	Order int `yaml:"order,omitempty" json:"order,omitempty"`
+	// Required
+	Items []MenuItem `yaml:"items" json:"items"`
+
+	i18n *localized[Menu]
+}
+
+// MenuItem is either a section (label and items) or a reference to a wrap.
+type MenuItem struct {
+	// Name of the referenced wrap. Empty for a section
+	Wrap string `yaml:"wrap,omitempty" json:"wrap,omitempty"`
+	// Required for a section. Default to the wrap label for a wrap reference
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+	// Optional. Default to the wrap icon for a wrap reference
+	Icon string `yaml:"icon,omitempty" json:"icon,omitempty"`
+	// Optional. Items of the same level are sorted by order, then by declaration order
+	Order int `yaml:"order,omitempty" json:"order,omitempty"`
+	// Section content
+	Items []MenuItem `yaml:"items,omitempty" json:"items,omitempty"`
+}
+
+func (m *Menu) Groom() error {
+	if m.ApiVersion != "krapper.kubotal.io/v1alpha1" {
+		return fmt.Errorf("invalid api version: %s", m.ApiVersion)
+	}
+	if m.Kind != "Menu" {
+		return fmt.Errorf("invalid Menu type: %s", m.Kind)
+	}
+	if m.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	for idx := range m.Items {
+		err := m.Items[idx].groom()
+		if err != nil {
+			return fmt.Errorf("item #%d: %v", idx, err)
+		}
+	}
+	return nil
+}
+
+func (i *MenuItem) groom() error {
+	if i.Wrap != "" {
+		if len(i.Items) > 0 {
+			return fmt.Errorf("a wrap reference ('%s') can't have items", i.Wrap)
+		}
+		return nil
+	}
+	if i.Label == "" {
+		return fmt.Errorf("a section must have a label")
+	}
+	for idx := range i.Items {
+		err := i.Items[idx].groom()
+		if err != nil {
+			return fmt.Errorf("section '%s': item #%d: %v", i.Label, idx, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package wrap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"krapper/internal/k8s"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// OwnerLabel is set on all objects of an ObjectSet, with a value identifying the primary object
+	OwnerLabel = "krapper.kubotal.io/owner"
+	// ObjectsAnnotation is set on the primary object, to record all objects of its set, in application order
+	ObjectsAnnotation = "krapper.kubotal.io/objects"
+)
+
+// ObjectRef identify a k8s object
+type ObjectRef struct {
+	ApiVersion string `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string `yaml:"kind" json:"kind"`
+	Namespace  string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Name       string `yaml:"name" json:"name"`
+}
+
+func refOf(obj *unstructured.Unstructured) ObjectRef {
+	return ObjectRef{ApiVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}
+
+func (r ObjectRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s '%s'", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s '%s/%s'", r.Kind, r.Namespace, r.Name)
+}
+
+// ObjectSet is the result of a template rendering: the primary object, which match the wrap source, and its companions.
+// All objects are handled as one unit.
+type ObjectSet struct {
+	Primary *unstructured.Unstructured
+	Objects []*unstructured.Unstructured // All objects, including the primary one, in application order
+	Owner   string                       // OwnerLabel value
+	// Objects of the set which are not applied, as left unchanged (i.e. the Secret of a not resubmitted value)
+	Kept []ObjectRef
+
+	wrap *Wrap
+}
+
+func newObjectSet(w *Wrap, primary *unstructured.Unstructured, objects []*unstructured.Unstructured) *ObjectSet {
+	s := &ObjectSet{
+		Primary: primary,
+		Objects: make([]*unstructured.Unstructured, 0, len(objects)),
+		Owner:   ownerValue(w.Name, primary),
+		wrap:    w,
+	}
+	s.Add(objects...)
+	return s
+}
+
+// Add objects to the set (i.e. the Secrets built by PrepareSecrets()), and update the ownership tags.
+func (s *ObjectSet) Add(objects ...*unstructured.Unstructured) {
+	s.Objects = append(s.Objects, objects...)
+	sort.SliceStable(s.Objects, func(i, j int) bool {
+		return kindRank(s.Objects[i].GetKind()) < kindRank(s.Objects[j].GetKind())
+	})
+	for _, obj := range s.Objects {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[OwnerLabel] = s.Owner
+		obj.SetLabels(labels)
+	}
+	s.annotate()
+}
+
+// Keep record objects which are part of the set, but not applied. They are not reported as stale.
+func (s *ObjectSet) Keep(refs ...ObjectRef) {
+	s.Kept = append(s.Kept, refs...)
+	s.annotate()
+}
+
+// annotate record all objects of the set on the primary one, in application order
+func (s *ObjectSet) annotate() {
+	refs := make([]ObjectRef, 0, len(s.Objects)+len(s.Kept))
+	for _, obj := range s.Objects {
+		refs = append(refs, refOf(obj))
+	}
+	for _, ref := range s.Kept {
+		if !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		return kindRank(refs[i].Kind) < kindRank(refs[j].Kind)
+	})
+	data, _ := json.Marshal(refs)
+	annotations := s.Primary.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[ObjectsAnnotation] = string(data)
+	s.Primary.SetAnnotations(annotations)
+}
+
+// Stale return the objects of the existing set (as recorded on the existing primary object) which are no longer part of this one.
+func (s *ObjectSet) Stale(existing *unstructured.Unstructured) []ObjectRef {
+	if existing == nil {
+		return []ObjectRef{}
+	}
+	current := make(map[ObjectRef]bool, len(s.Objects)+len(s.Kept))
+	for _, obj := range s.Objects {
+		current[refOf(obj)] = true
+	}
+	for _, ref := range s.Kept {
+		current[ref] = true
+	}
+	stale := make([]ObjectRef, 0)
+	for _, ref := range OwnedObjects(existing) {
+		if !current[ref] {
+			stale = append(stale, ref)
+		}
+	}
+	return stale
+}
+
+// OwnedObjects return the objects of the set of a primary object, in application order.
+// Objects not created by krapper are their own set.
+func OwnedObjects(primary *unstructured.Unstructured) []ObjectRef {
+	var refs []ObjectRef
+	if err := json.Unmarshal([]byte(primary.GetAnnotations()[ObjectsAnnotation]), &refs); err != nil || len(refs) == 0 {
+		return []ObjectRef{refOf(primary)}
+	}
+	return refs
+}
+
+// kindOrder is the application order of the usual kinds. Others are applied last, in template order.
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+}
+
+func kindRank(kind string) int {
+	for idx, k := range kindOrder {
+		if k == kind {
+			return idx
+		}
+	}
+	return len(kindOrder)
+}
+
+var labelValueRegex = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// ownerValue is '<wrap>.<namespace>.<name>' (or '<wrap>.<name>' for a cluster scoped primary),
+// replaced by a hash if not a valid label value.
+func ownerValue(wrapName string, primary *unstructured.Unstructured) string {
+	elems := []string{wrapName, primary.GetName()}
+	if primary.GetNamespace() != "" {
+		elems = []string{wrapName, primary.GetNamespace(), primary.GetName()}
+	}
+	value := strings.Join(elems, ".")
+	if len(value) <= 63 && labelValueRegex.MatchString(value) {
+		return value
+	}
+	sum := sha256.Sum256([]byte(strings.Join(elems, "/")))
+	return "h-" + hex.EncodeToString(sum[:])[:40]
+}
+
+// ApplyObjects apply all objects of the set, in order. If one fails, the previously applied ones are rolled back:
+// created objects are deleted, updated ones are restored to their previous state.
+// An existing object owned by another set is never overwritten. Nor is an existing companion not owned by this set,
+// while an existing primary object without owner (i.e. created by kubectl) is adopted, provided it matches the wrap selector.
+// Return the applied primary object.
+func (s *ObjectSet) ApplyObjects(ctx context.Context, client k8s.Client) (*unstructured.Unstructured, error) {
+	var primary *unstructured.Unstructured
+	previous := make([]*unstructured.Unstructured, 0, len(s.Objects))
+	for _, obj := range s.Objects {
+		existing, err := client.GetResource(ctx, obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, s.rollback(ctx, client, previous, fmt.Errorf("unable to get %s: %w", refOf(obj), err))
+		}
+		if err != nil {
+			existing = nil
+		}
+		if existing != nil {
+			owner, owned := existing.GetLabels()[OwnerLabel]
+			if (owned && owner != s.Owner) || (!owned && (obj != s.Primary || !s.wrap.Selects(existing))) {
+				// A conflict, as an object owned by another manager
+				cause := apierrors.NewConflict(schema.GroupResource{Resource: obj.GetKind()}, obj.GetName(), fmt.Errorf("%s already exists, and is not owned by this object", refOf(obj)))
+				return nil, s.rollback(ctx, client, previous, cause)
+			}
+		}
+		applied, err := client.ApplyResource(ctx, obj)
+		if err != nil {
+			return nil, s.rollback(ctx, client, previous, err)
+		}
+		previous = append(previous, existing)
+		if obj == s.Primary {
+			primary = applied
+		}
+	}
+	return primary, nil
+}
+
+// rollback undo the application of the first len(previous) objects, in reverse order
+func (s *ObjectSet) rollback(ctx context.Context, client k8s.Client, previous []*unstructured.Unstructured, cause error) error {
+	errs := []error{cause}
+	for idx := len(previous) - 1; idx >= 0; idx-- {
+		obj := s.Objects[idx]
+		var err error
+		if previous[idx] == nil {
+			err = client.DeleteResource(ctx, obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		} else {
+			_, err = client.ApplyResource(ctx, restorable(previous[idx]))
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("unable to rollback %s: %w", refOf(obj), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restorable strip the server populated fields of an object, to apply it again
+func restorable(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	for _, field := range []string{"resourceVersion", "uid", "generation", "creationTimestamp", "managedFields"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	delete(obj.Object, "status")
+	return obj
+}
+
+// DeleteObjects delete the objects, in reverse order. Objects not labeled with owner (the OwnerLabel value of the
+// primary object) are left untouched, as well as already deleted ones.
+func DeleteObjects(ctx context.Context, client k8s.Client, refs []ObjectRef, owner string) error {
+	errs := make([]error, 0)
+	for idx := len(refs) - 1; idx >= 0; idx-- {
+		ref := refs[idx]
+		obj, err := client.GetResource(ctx, ref.ApiVersion, ref.Kind, ref.Namespace, ref.Name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("unable to get %s: %w", ref, err))
+			}
+			continue
+		}
+		if obj.GetLabels()[OwnerLabel] != owner {
+			continue
+		}
+		err = client.DeleteResource(ctx, ref.ApiVersion, ref.Kind, ref.Namespace, ref.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("unable to delete %s: %w", ref, err))
+		}
+	}
+	return errors.Join(errs...)
+}
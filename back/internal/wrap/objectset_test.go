@@ -0,0 +1,280 @@
+package wrap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const objectSetWrap = `
+schema:
+  fields:
+    - name: name
+      required: true
+    - name: password
+      required: true
+    - name: withConfig
+      boolean: {}
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .Fields.name }}
+    namespace: ns1
+  {{- if .Fields.withConfig }}
+  ---
+  apiVersion: v1
+  kind: ServiceAccount
+  metadata:
+    name: {{ .Fields.name }}-config
+    namespace: ns1
+  {{- end }}
+  ---
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: {{ .Fields.name }}-password
+    namespace: ns1
+  stringData:
+    password: {{ .Fields.password }}
+  ---
+`
+
+func renderTestSet(t *testing.T, w *Wrap, payload string) *ObjectSet {
+	t.Helper()
+	values, _, err := w.CheckValues(decodeValues(t, payload), nil)
+	checkErrorPaths(t, err)
+	set, err := w.Render(&TemplateData{Fields: values, Metadata: map[string]interface{}{}})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	return set
+}
+
+func objectNames(client *fakeClient) string {
+	names := make([]string, 0, len(client.objects))
+	for _, obj := range client.objects {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+	}
+	return strings.Join(names, " ")
+}
+
+func TestObjectSet(t *testing.T) {
+	w := parseTestWrap(t, objectSetWrap)
+	set := renderTestSet(t, w, `{"name": "u1", "password": "x", "withConfig": true}`)
+	if set.Primary.GetKind() != "ConfigMap" || len(set.Objects) != 3 {
+		t.Fatalf("Unexpected set: primary %s, %d objects", set.Primary.GetKind(), len(set.Objects))
+	}
+	// Dependency order
+	if set.Objects[0].GetKind() != "ServiceAccount" || set.Objects[1].GetKind() != "Secret" || set.Objects[2] != set.Primary {
+		t.Errorf("Unexpected application order: %s, %s, %s", set.Objects[0].GetKind(), set.Objects[1].GetKind(), set.Objects[2].GetKind())
+	}
+	for _, obj := range set.Objects {
+		if obj.GetLabels()[OwnerLabel] != "test.ns1.u1" {
+			t.Errorf("Unexpected owner label on %s: %v", obj.GetKind(), obj.GetLabels())
+		}
+	}
+
+	ctx := context.Background()
+	client := &fakeClient{}
+	if _, err := set.ApplyObjects(ctx, client); err != nil {
+		t.Fatalf("ApplyObjects() failed: %v", err)
+	}
+	if got := objectNames(client); got != "ServiceAccount/u1-config Secret/u1-password ConfigMap/u1" {
+		t.Errorf("Unexpected applied objects: %s", got)
+	}
+	existing, _ := client.GetResource(ctx, "v1", "ConfigMap", "ns1", "u1")
+
+	// Without config, the ServiceAccount is stale
+	set = renderTestSet(t, w, `{"name": "u1", "password": "y"}`)
+	stale := set.Stale(existing)
+	if len(stale) != 1 || stale[0].Kind != "ServiceAccount" {
+		t.Errorf("Unexpected stale objects: %v", stale)
+	}
+
+	// Failure on the primary object: the Secret is restored, the ServiceAccount created by this update is deleted
+	set = renderTestSet(t, w, `{"name": "u1", "password": "z", "withConfig": true}`)
+	_ = client.DeleteResource(ctx, "v1", "ServiceAccount", "ns1", "u1-config")
+	client.failApply = "ConfigMap"
+	_, err := set.ApplyObjects(ctx, client)
+	if err == nil || !strings.Contains(err.Error(), "failed to apply ConfigMap") {
+		t.Errorf("Expected apply error, got %v", err)
+	}
+	if got := objectNames(client); got != "ConfigMap/u1 Secret/u1-password" {
+		t.Errorf("Unexpected objects after rollback: %s", got)
+	}
+	secret, _ := client.GetResource(ctx, "v1", "Secret", "ns1", "u1-password")
+	if secret.Object["stringData"].(map[string]interface{})["password"] != "x" {
+		t.Errorf("Expected secret to be restored, got %v", secret.Object["stringData"])
+	}
+
+	// Deletion of the whole set, sparing objects of another owner
+	client.objects[1].SetLabels(map[string]string{OwnerLabel: "other"})
+	if err := DeleteObjects(ctx, client, OwnedObjects(existing), existing.GetLabels()[OwnerLabel]); err != nil {
+		t.Errorf("DeleteObjects() failed: %v", err)
+	}
+	if got := objectNames(client); got != "Secret/u1-password" {
+		t.Errorf("Unexpected objects after deletion: %s", got)
+	}
+}
+
+func TestObjectSetKeptSecret(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: name
+    - name: comment
+    - name: password
+      required: true
+      secret:
+        value: ".data.passwordRef"
+        secret:
+          name: "name + '-pwd'"
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .Fields.name }}
+    namespace: ns1
+  data:
+    comment: {{ .Fields.comment | default "" | quote }}
+    {{- with .Fields.password }}
+    passwordRef: {{ .name }}
+    {{- end }}
+`)
+	ctx := context.Background()
+	client := &fakeClient{}
+	put := func(payload string) {
+		t.Helper()
+		values, inactive, err := w.CheckValues(decodeValues(t, payload), nil)
+		checkErrorPaths(t, err)
+		secrets, err := w.PrepareSecrets(values, "ns1")
+		if err != nil {
+			t.Fatalf("PrepareSecrets() failed: %v", err)
+		}
+		set, err := w.Render(&TemplateData{Fields: values, Metadata: map[string]interface{}{}})
+		if err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		existing, err := client.GetResource(ctx, "v1", "ConfigMap", "ns1", set.Primary.GetName())
+		if err != nil {
+			existing = nil
+		}
+		kept, err := w.KeepSecrets(set.Primary, existing, values, inactive)
+		if err != nil {
+			t.Fatalf("KeepSecrets() failed: %v", err)
+		}
+		set.Add(secrets...)
+		set.Keep(kept...)
+		if _, err := set.ApplyObjects(ctx, client); err != nil {
+			t.Fatalf("ApplyObjects() failed: %v", err)
+		}
+		if err := DeleteObjects(ctx, client, set.Stale(existing), set.Owner); err != nil {
+			t.Fatalf("DeleteObjects() failed: %v", err)
+		}
+	}
+	put(`{"name": "u1", "password": "x"}`)
+	if got := objectNames(client); got != "Secret/u1-pwd ConfigMap/u1" {
+		t.Fatalf("Unexpected objects after creation: %s", got)
+	}
+
+	// Update without the password: the Secret is kept, and still part of the set
+	put(`{"name": "u1", "comment": "b"}`)
+	if got := objectNames(client); got != "Secret/u1-pwd ConfigMap/u1" {
+		t.Errorf("Unexpected objects after update: %s", got)
+	}
+	primary, _ := client.GetResource(ctx, "v1", "ConfigMap", "ns1", "u1")
+	if primary.Object["data"].(map[string]interface{})["passwordRef"] != "u1-pwd" {
+		t.Errorf("Secret reference not kept: %v", primary.Object["data"])
+	}
+	if refs := OwnedObjects(primary); len(refs) != 2 || refs[0].Name != "u1-pwd" {
+		t.Errorf("Kept Secret not recorded in the set: %v", refs)
+	}
+}
+
+func TestRenderWithoutPrimary(t *testing.T) {
+	w := parseTestWrap(t, `
+template: |
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: s1
+`)
+	_, err := w.Render(&TemplateData{Fields: map[string]interface{}{}, Metadata: map[string]interface{}{}})
+	if err == nil || !strings.Contains(err.Error(), "produce no v1/ConfigMap object") {
+		t.Errorf("Expected missing primary error, got %v", err)
+	}
+}
+
+func TestApplyOwnership(t *testing.T) {
+	w := parseTestWrap(t, objectSetWrap)
+	ctx := context.Background()
+
+	// An existing primary object without owner is adopted
+	client := &fakeClient{objects: []*unstructured.Unstructured{newTestObject("v1", "ConfigMap", "ns1", "u1", nil)}}
+	set := renderTestSet(t, w, `{"name": "u1", "password": "x"}`)
+	if _, err := set.ApplyObjects(ctx, client); err != nil {
+		t.Fatalf("ApplyObjects() failed: %v", err)
+	}
+
+	// Provided it matches the wrap selector. Otherwise, it belongs to another wrap
+	w.Source.Selector = map[string]string{"app": "users"}
+	adoptable := newTestObject("v1", "ConfigMap", "ns1", "u1", nil)
+	adoptable.SetLabels(map[string]string{"app": "users"})
+	for _, existing := range []*unstructured.Unstructured{newTestObject("v1", "ConfigMap", "ns1", "u1", nil), adoptable} {
+		client = &fakeClient{objects: []*unstructured.Unstructured{existing}}
+		set = renderTestSet(t, w, `{"name": "u1", "password": "x"}`)
+		_, err := set.ApplyObjects(ctx, client)
+		if existing == adoptable && err != nil {
+			t.Errorf("Expected object matching the selector to be adopted, got %v", err)
+		}
+		if existing != adoptable && !apierrors.IsConflict(err) {
+			t.Errorf("Expected conflict on object not matching the selector, got %v", err)
+		}
+	}
+	w.Source.Selector = nil
+
+	// An existing companion not owned by the set is never overwritten
+	for _, labels := range []map[string]string{nil, {OwnerLabel: "other"}} {
+		secret := newTestObject("v1", "Secret", "ns1", "u2-password", nil)
+		secret.SetLabels(labels)
+		client = &fakeClient{objects: []*unstructured.Unstructured{secret}}
+		set = renderTestSet(t, w, `{"name": "u2", "password": "x", "withConfig": true}`)
+		_, err := set.ApplyObjects(ctx, client)
+		if !apierrors.IsConflict(err) || !strings.Contains(err.Error(), "is not owned by this object") {
+			t.Errorf("Expected ownership error with labels %v, got %v", labels, err)
+		}
+		// The ServiceAccount applied before is rolled back
+		if got := objectNames(client); got != "Secret/u2-password" {
+			t.Errorf("Unexpected objects after rollback: %s", got)
+		}
+	}
+}
+
+func TestRenderCompanionNamespace(t *testing.T) {
+	w := parseTestWrap(t, `
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: c1
+    namespace: ns1
+  ---
+  apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: r1
+  ---
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: s1
+`)
+	_, err := w.Render(&TemplateData{Fields: map[string]interface{}{}, Metadata: map[string]interface{}{}})
+	if err == nil || !strings.Contains(err.Error(), "companion without namespace: Secret 's1'") {
+		t.Errorf("Expected companion namespace error, got %v", err)
+	}
+}
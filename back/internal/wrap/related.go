@@ -0,0 +1,164 @@
+package wrap
+
+import (
+	"context"
+	"fmt"
+	"krapper/internal/k8s"
+	"krapper/internal/misc"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Related describe objects linked to the wrapped one (i.e. the HelmReleases and Pods produced by a Release).
+// Candidates are selected by ownerReferences and/or by a label selector built from the parent object.
+type Related struct {
+	// Required
+	Name string `yaml:"name" json:"name"`
+	// Optional. Default to the labelized name
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+	// Either Wrap or ApiVersion/Kind must be defined. With Wrap, the related objects are decorated by this wrap.
+	Wrap       string `yaml:"wrap,omitempty" json:"wrap,omitempty"`
+	ApiVersion string `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty" json:"kind,omitempty"`
+	// Optional. Evaluated against the parent object (as 'resource'). Default to the parent namespace (All namespaces
+	// for a cluster scoped parent). All namespaces if empty
+	Namespace Cel `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	// If true, only objects with an ownerReference to the parent object are selected
+	OwnerReference bool `yaml:"ownerReference,omitempty" json:"ownerReference,omitempty"`
+	// Label values are evaluated against the parent object (i.e. {app.kubernetes.io/instance: "resource.metadata.name"})
+	Selector map[string]Cel `yaml:"selector,omitempty" json:"selector,omitempty"`
+}
+
+// RelatedObjects are the objects found for a Related definition
+type RelatedObjects struct {
+	Name       string                      `yaml:"name" json:"name"`
+	Label      string                      `yaml:"label" json:"label"`
+	Wrap       string                      `yaml:"wrap,omitempty" json:"wrap,omitempty"`
+	ApiVersion string                      `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string                      `yaml:"kind" json:"kind"`
+	Items      []unstructured.Unstructured `yaml:"items" json:"items"`
+	Error      string                      `yaml:"error,omitempty" json:"error,omitempty"` // i.e. kind not served by the cluster
+}
+
+func (r *Related) groom() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Label == "" {
+		r.Label = misc.Labelize(r.Name)
+	}
+	if r.Wrap == "" && (r.ApiVersion == "" || r.Kind == "") {
+		return fmt.Errorf("related '%s' must define either a wrap or an apiVersion/kind", r.Name)
+	}
+	if r.Wrap != "" && (r.ApiVersion != "" || r.Kind != "") {
+		return fmt.Errorf("related '%s' can't define both a wrap and an apiVersion/kind", r.Name)
+	}
+	if !r.OwnerReference && len(r.Selector) == 0 {
+		return fmt.Errorf("related '%s' must define ownerReference and/or selector", r.Name)
+	}
+	if r.Namespace == "" {
+		r.Namespace = "has(resource.metadata.namespace) ? resource.metadata.namespace : ''"
+	}
+	if err := validCel(r.Namespace); err != nil {
+		return fmt.Errorf("related '%s': invalid namespace: %w", r.Name, err)
+	}
+	for key, value := range r.Selector {
+		if err := validCel(value); err != nil {
+			return fmt.Errorf("related '%s': invalid selector '%s': %w", r.Name, key, err)
+		}
+	}
+	return nil
+}
+
+// ListRelated return the objects related to parent, for each Related definition.
+// A failing definition (i.e. unknown kind, or unknown wrap) report an error, without preventing the others.
+func (w *Wrap) ListRelated(ctx context.Context, client k8s.Client, wrapLookup WrapLookup, parent *unstructured.Unstructured) []RelatedObjects {
+	result := make([]RelatedObjects, 0, len(w.Related))
+	for idx := range w.Related {
+		result = append(result, w.Related[idx].list(ctx, client, wrapLookup, parent))
+	}
+	return result
+}
+
+func (r *Related) list(ctx context.Context, client k8s.Client, wrapLookup WrapLookup, parent *unstructured.Unstructured) RelatedObjects {
+	objects := RelatedObjects{
+		Name:       r.Name,
+		Label:      r.Label,
+		Wrap:       r.Wrap,
+		ApiVersion: r.ApiVersion,
+		Kind:       r.Kind,
+		Items:      []unstructured.Unstructured{},
+	}
+	var target *Wrap
+	selector := make(map[string]string)
+	if r.Wrap != "" {
+		target = wrapLookup(r.Wrap)
+		if target == nil {
+			objects.Error = fmt.Sprintf("wrap '%s' not found", r.Wrap)
+			return objects
+		}
+		objects.ApiVersion, objects.Kind = target.Source.ApiVersion, target.Source.Kind
+		for k, v := range target.Source.Selector {
+			selector[k] = v
+		}
+	}
+	activation := resourceActivation(parent.Object)
+	for key, exp := range r.Selector {
+		value, err := evalCel(exp, activation)
+		if err != nil {
+			objects.Error = fmt.Sprintf("selector '%s': %v", key, err)
+			return objects
+		}
+		selector[key] = fmt.Sprintf("%v", value)
+	}
+	var ns string
+	if target != nil && target.Source.Namespace != "" {
+		ns = target.Source.Namespace
+	} else {
+		// A failure must not turn into a search in all namespaces
+		value, err := evalCel(r.Namespace, activation)
+		if err != nil {
+			objects.Error = fmt.Sprintf("namespace: %v", err)
+			return objects
+		}
+		if value != nil {
+			ns = fmt.Sprintf("%v", value)
+		}
+	}
+	list, err := client.ListResources(ctx, objects.ApiVersion, objects.Kind, ns, selector)
+	if err != nil {
+		objects.Error = err.Error()
+		return objects
+	}
+	for _, item := range list.Items {
+		if r.OwnerReference && !ownedBy(&item, parent) {
+			continue
+		}
+		item.SetManagedFields(nil)
+		if target != nil {
+			target.Decorate(&item)
+		} else if objects.ApiVersion == "v1" && objects.Kind == "Secret" {
+			// Without a wrap to mask them, values of Secrets are never returned
+			delete(item.Object, "data")
+			delete(item.Object, "stringData")
+		}
+		objects.Items = append(objects.Items, item)
+	}
+	sort.SliceStable(objects.Items, func(i, j int) bool {
+		if objects.Items[i].GetNamespace() != objects.Items[j].GetNamespace() {
+			return objects.Items[i].GetNamespace() < objects.Items[j].GetNamespace()
+		}
+		return objects.Items[i].GetName() < objects.Items[j].GetName()
+	})
+	return objects
+}
+
+func ownedBy(obj *unstructured.Unstructured, owner *unstructured.Unstructured) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() && owner.GetUID() != "" {
+			return true
+		}
+	}
+	return false
+}
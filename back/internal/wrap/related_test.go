@@ -0,0 +1,102 @@
+package wrap
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRelated(t *testing.T) {
+	w := parseTestWrap(t, `
+related:
+  - name: children
+    apiVersion: v1
+    kind: Secret
+    ownerReference: true
+  - name: pods
+    wrap: pods
+    namespace: "resource.data.target"
+    selector:
+      app: "resource.metadata.name"
+  - name: missing
+    wrap: unknown
+    ownerReference: true
+  - name: badNamespace
+    apiVersion: v1
+    kind: Pod
+    namespace: "resource.data.unknown"
+    ownerReference: true
+`)
+	pods := parseTestWrap(t, `
+status:
+  phases:
+    ready: [Running]
+`)
+	pods.Source.Kind = "Pod"
+	parent := newTestObject("v1", "ConfigMap", "ns1", "app1", nil)
+	parent.SetUID("uid1")
+	parent.Object["data"] = map[string]interface{}{"target": "ns2"}
+
+	owned := newTestObject("v1", "Secret", "ns1", "s1", nil)
+	owned.SetOwnerReferences([]metav1.OwnerReference{{UID: "uid1", Kind: "ConfigMap", Name: "app1"}})
+	owned.Object["data"] = map[string]interface{}{"password": "c2VjcmV0"}
+	owned.Object["stringData"] = map[string]interface{}{"token": "secret"}
+	notOwned := newTestObject("v1", "Secret", "ns1", "s2", nil)
+	pod := newTestObject("v1", "Pod", "ns2", "p1", nil)
+	pod.SetLabels(map[string]string{"app": "app1"})
+	pod.Object["status"] = map[string]interface{}{"phase": "Running"}
+	otherPod := newTestObject("v1", "Pod", "ns1", "p2", nil)
+	otherPod.SetLabels(map[string]string{"app": "app1"})
+
+	client := &fakeClient{objects: []*unstructured.Unstructured{parent, owned, notOwned, pod, otherPod}}
+	lookup := func(name string) *Wrap {
+		if name == "pods" {
+			return pods
+		}
+		return nil
+	}
+	related := w.ListRelated(context.Background(), client, lookup, parent)
+	if len(related) != 4 {
+		t.Fatalf("Expected 4 related groups, got %d", len(related))
+	}
+	if len(related[0].Items) != 1 || related[0].Items[0].GetName() != "s1" || related[0].Label != "Children" {
+		t.Fatalf("Unexpected owned objects: %+v", related[0])
+	}
+	if _, ok := related[0].Items[0].Object["data"]; ok {
+		t.Errorf("Secret data returned: %v", related[0].Items[0].Object)
+	}
+	if _, ok := related[0].Items[0].Object["stringData"]; ok {
+		t.Errorf("Secret stringData returned: %v", related[0].Items[0].Object)
+	}
+	if _, ok := owned.Object["data"]; !ok {
+		t.Errorf("Stored Secret modified")
+	}
+	if len(related[1].Items) != 1 || related[1].Items[0].GetName() != "p1" || related[1].Kind != "Pod" {
+		t.Fatalf("Unexpected selected objects: %+v", related[1])
+	}
+	decoration := related[1].Items[0].Object[DecorationKey].(map[string]interface{})
+	if decoration["status"].(map[string]interface{})["health"] != "ready" {
+		t.Errorf("Expected related objects to be decorated by their wrap, got %v", decoration)
+	}
+	if related[2].Error == "" || len(related[2].Items) != 0 {
+		t.Errorf("Expected error for unknown wrap, got %+v", related[2])
+	}
+	// A failing namespace expression is not a search in all namespaces
+	if related[3].Error == "" || len(related[3].Items) != 0 {
+		t.Errorf("Expected error for failing namespace, got %+v", related[3])
+	}
+
+	// A cluster scoped parent default to all namespaces
+	clusterParent := newTestObject("v1", "Namespace", "", "ns1", nil)
+	clusterParent.SetUID("uid1")
+	related = w.ListRelated(context.Background(), client, lookup, clusterParent)
+	if related[0].Error != "" || len(related[0].Items) != 1 {
+		t.Errorf("Unexpected related objects of a cluster scoped parent: %+v", related[0])
+	}
+
+	if _, err := Parse([]byte(testWrapHeader+"related:\n  - name: a\n    apiVersion: v1\n    kind: Pod\n"), "test"); err == nil {
+		t.Errorf("Expected error on related without ownerReference or selector")
+	}
+}
@@ -0,0 +1,105 @@
+package wrap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// TemplateData is the data model provided to Wrap.Template
+type TemplateData struct {
+	Fields   map[string]interface{} // Checked values, by field name
+	Metadata map[string]interface{} // Target object metadata (i.e. namespace)
+}
+
+func parseTemplate(name string, tmpl WrTemplate) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs()).Parse(string(tmpl))
+}
+
+func templateFuncs() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	funcs["toYaml"] = func(v interface{}) string {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(bytes.TrimSuffix(data, []byte("\n")))
+	}
+	funcs["fromYaml"] = func(s string) map[string]interface{} {
+		m := make(map[string]interface{})
+		if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+			m["Error"] = err.Error()
+		}
+		return m
+	}
+	return funcs
+}
+
+// Render execute the template and return the resulting objects. The template may produce several yaml documents:
+// The first object matching the wrap source is the primary one, others are its companions.
+// Companions must define their namespace, except the ones of well known cluster scoped kinds.
+func (w *Wrap) Render(data *TemplateData) (*ObjectSet, error) {
+	if w.template == nil {
+		return nil, fmt.Errorf("wrap '%s' has no template", w.Name)
+	}
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error executing template of wrap '%s': %w", w.Name, err)
+	}
+	objects := make([]*unstructured.Unstructured, 0, 1)
+	var primary *unstructured.Unstructured
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(&buf))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("template of wrap '%s' does not produce valid yaml: %w", w.Name, err)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &obj.Object); err != nil {
+			return nil, fmt.Errorf("template of wrap '%s' does not produce valid yaml: %w", w.Name, err)
+		}
+		if len(obj.Object) == 0 {
+			continue // Empty document (i.e. a conditional one)
+		}
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("template of wrap '%s' produce an object without apiVersion, kind or name", w.Name)
+		}
+		if primary == nil && obj.GetAPIVersion() == w.Source.ApiVersion && obj.GetKind() == w.Source.Kind {
+			primary = obj
+		}
+		objects = append(objects, obj)
+	}
+	if primary == nil {
+		return nil, fmt.Errorf("template of wrap '%s' produce no %s/%s object", w.Name, w.Source.ApiVersion, w.Source.Kind)
+	}
+	for _, obj := range objects {
+		if obj != primary && obj.GetNamespace() == "" && !clusterScopedKinds[obj.GetKind()] {
+			return nil, fmt.Errorf("template of wrap '%s' produce a companion without namespace: %s", w.Name, refOf(obj))
+		}
+	}
+	return newObjectSet(w, primary, objects), nil
+}
+
+// clusterScopedKinds are the well known kinds of companions which have no namespace
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                      true,
+	"CustomResourceDefinition":       true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"PersistentVolume":               true,
+	"StorageClass":                   true,
+	"PriorityClass":                  true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"ValidatingWebhookConfiguration": true,
+}
@@ -0,0 +1,82 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: name
+      required: true
+    - name: color
+      string:
+        enum: [red, green]
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .Fields.name }}
+    namespace: {{ .Metadata.namespace }}
+  data:
+    color: {{ .Fields.color | quote }}
+`)
+	_, _, err := w.CheckValues(decodeValues(t, `{"color": "red"}`), nil)
+	checkErrorPaths(t, err, "name")
+
+	values, _, err := w.CheckValues(decodeValues(t, `{"name": "cm1", "color": "red"}`), nil)
+	checkErrorPaths(t, err)
+	set, err := w.Render(&TemplateData{Fields: values, Metadata: map[string]interface{}{"namespace": "ns1"}})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	obj := set.Primary
+	if obj.GetName() != "cm1" || obj.GetNamespace() != "ns1" {
+		t.Errorf("Unexpected object identity: %s/%s", obj.GetNamespace(), obj.GetName())
+	}
+	if color := obj.Object["data"].(map[string]interface{})["color"]; color != "red" {
+		t.Errorf("Expected data.color to be 'red', got %v", color)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		template string
+		expected string
+	}{
+		"no template": {expected: "has no template"},
+		"execution error": {
+			template: "{{ fail \"boom\" }}",
+			expected: "error executing template",
+		},
+		"invalid yaml": {
+			template: "apiVersion: v1\nkind: [ConfigMap\n",
+			expected: "does not produce valid yaml",
+		},
+		"no name": {
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata: {}\n",
+			expected: "without apiVersion, kind or name",
+		},
+		"no primary": {
+			template: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: s1\n",
+			expected: "produce no v1/ConfigMap object",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := parseTestWrap(t, "")
+			if tc.template != "" {
+				tmpl, err := parseTemplate(w.Name, WrTemplate(tc.template))
+				if err != nil {
+					t.Fatalf("parseTemplate() failed: %v", err)
+				}
+				w.template = tmpl
+			}
+			_, err := w.Render(&TemplateData{})
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package wrap
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type Health string
+
+const (
+	healthReady       Health = "ready"
+	healthProgressing Health = "progressing"
+	healthDegraded    Health = "degraded"
+	healthUnknown     Health = "unknown"
+)
+
+var validHealths = map[Health]bool{healthReady: true, healthProgressing: true, healthDegraded: true, healthUnknown: true}
+
+// Status define how the health of an object is computed. Rules are evaluated first, then the condition, then the phase.
+// If none applies, the health is 'unknown'.
+type Status struct {
+	// Optional. Evaluated in order. First matching wins
+	Rules []StatusRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+	// Optional. Type of the '.status.conditions' entry to use (i.e. 'Ready'). Status 'True' is ready, 'False' is degraded
+	// and 'Unknown' is progressing. Also progressing if the condition observedGeneration is behind the object generation.
+	Condition string `yaml:"condition,omitempty" json:"condition,omitempty"`
+	// Optional. Expression of the phase. Default to 'resource.status.phase' if Phases is defined
+	Phase Cel `yaml:"phase,omitempty" json:"phase,omitempty"`
+	// Optional. Phase values by health (i.e. {ready: [READY], degraded: [ERROR]})
+	Phases map[Health][]string `yaml:"phases,omitempty" json:"phases,omitempty"`
+}
+
+// StatusRule set the health of objects matching When. Expressions are evaluated against the object (as 'resource')
+type StatusRule struct {
+	When    Cel    `yaml:"when" json:"when"`
+	Health  Health `yaml:"health" json:"health"`
+	Reason  Cel    `yaml:"reason,omitempty" json:"reason,omitempty"`
+	Message Cel    `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// ObjectStatus is the normalized health of an object, reported to the front under the DecorationKey property
+type ObjectStatus struct {
+	Health  Health `yaml:"health" json:"health"`
+	Reason  string `yaml:"reason,omitempty" json:"reason,omitempty"`
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+func (s *Status) groom() error {
+	for idx := range s.Rules {
+		rule := &s.Rules[idx]
+		if rule.When == "" {
+			return fmt.Errorf("rule #%d: 'when' is required", idx)
+		}
+		if !validHealths[rule.Health] {
+			return fmt.Errorf("rule #%d: invalid health '%s'", idx, rule.Health)
+		}
+		for _, exp := range []Cel{rule.When, rule.Reason, rule.Message} {
+			if err := validCel(exp); err != nil {
+				return fmt.Errorf("rule #%d: %w", idx, err)
+			}
+		}
+	}
+	for health := range s.Phases {
+		if !validHealths[health] {
+			return fmt.Errorf("invalid health '%s' in phases", health)
+		}
+	}
+	if s.Phase == "" && len(s.Phases) > 0 {
+		s.Phase = "resource.status.phase"
+	}
+	return validCel(s.Phase)
+}
+
+// Health compute the normalized status of an object. Return nil if the wrap has no status section.
+func (w *Wrap) Health(obj *unstructured.Unstructured) *ObjectStatus {
+	if w.Status == nil {
+		return nil
+	}
+	activation := resourceActivation(obj.Object)
+	for _, rule := range w.Status.Rules {
+		result, err := evalCel(rule.When, activation)
+		if matched, _ := result.(bool); err != nil || !matched {
+			continue
+		}
+		return &ObjectStatus{
+			Health:  rule.Health,
+			Reason:  evalText(rule.Reason, activation),
+			Message: evalText(rule.Message, activation),
+		}
+	}
+	if w.Status.Condition != "" {
+		if status := conditionStatus(obj, w.Status.Condition); status != nil {
+			return status
+		}
+	}
+	if w.Status.Phase != "" {
+		if phase := evalText(w.Status.Phase, activation); phase != "" {
+			for _, health := range []Health{healthReady, healthProgressing, healthDegraded, healthUnknown} {
+				for _, p := range w.Status.Phases[health] {
+					if strings.EqualFold(p, phase) {
+						return &ObjectStatus{Health: health, Reason: phase}
+					}
+				}
+			}
+			return &ObjectStatus{Health: healthUnknown, Reason: phase}
+		}
+	}
+	return &ObjectStatus{Health: healthUnknown}
+}
+
+// conditionStatus map the condition of type conditionType. Return nil if not found
+func conditionStatus(obj *unstructured.Unstructured, conditionType string) *ObjectStatus {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+		status := &ObjectStatus{Health: healthUnknown}
+		status.Reason, _ = condition["reason"].(string)
+		status.Message, _ = condition["message"].(string)
+		switch condition["status"] {
+		case "True":
+			status.Health = healthReady
+		case "False":
+			status.Health = healthDegraded
+		case "Unknown":
+			status.Health = healthProgressing
+		}
+		observed, found, _ := unstructured.NestedInt64(condition, "observedGeneration")
+		if found && observed < obj.GetGeneration() {
+			status.Health = healthProgressing
+		}
+		return status
+	}
+	return nil
+}
+
+// evalText evaluate an optional expression as a string. Errors result in an empty string
+func evalText(exp Cel, activation map[string]interface{}) string {
+	if exp == "" {
+		return ""
+	}
+	result, err := evalCel(exp, activation)
+	if err != nil || result == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", result)
+}
+
+// Decorate prepare an object to be returned to the front: secrets are masked (See MaskSecrets()), and the
+// computed status is added under the DecorationKey property
+func (w *Wrap) Decorate(obj *unstructured.Unstructured) {
+	w.MaskSecrets(obj)
+	status := w.Health(obj)
+	if status == nil {
+		return
+	}
+	decoration, ok := obj.Object[DecorationKey].(map[string]interface{})
+	if !ok {
+		decoration = make(map[string]interface{})
+		obj.Object[DecorationKey] = decoration
+	}
+	decoration["status"] = map[string]interface{}{
+		"health":  string(status.Health),
+		"reason":  status.Reason,
+		"message": status.Message,
+	}
+}
+
+// MatchHealth return true if the computed health of the object is one of healths. Always true if healths is empty.
+func (w *Wrap) MatchHealth(obj *unstructured.Unstructured, healths []string) bool {
+	if len(healths) == 0 {
+		return true
+	}
+	health := healthUnknown
+	if status := w.Health(obj); status != nil {
+		health = status.Health
+	}
+	for _, h := range healths {
+		if Health(h) == health {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package wrap
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestHealth(t *testing.T) {
+	w := parseTestWrap(t, `
+status:
+  rules:
+    - when: "has(resource.spec.suspended) && resource.spec.suspended"
+      health: unknown
+      reason: "'Suspended'"
+      message: "'Suspended by ' + resource.spec.suspendedBy"
+  condition: Ready
+  phases:
+    ready: [READY]
+    degraded: [ERROR]
+`)
+	tests := []struct {
+		name     string
+		object   map[string]interface{}
+		expected ObjectStatus
+	}{
+		{"rule", map[string]interface{}{"spec": map[string]interface{}{"suspended": true, "suspendedBy": "jdoe"}},
+			ObjectStatus{Health: healthUnknown, Reason: "Suspended", Message: "Suspended by jdoe"}},
+		{"conditionTrue", map[string]interface{}{"status": map[string]interface{}{"phase": "ERROR", "conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "True", "reason": "Deployed", "message": "All good"}}}},
+			ObjectStatus{Health: healthReady, Reason: "Deployed", Message: "All good"}},
+		{"conditionFalse", map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "Failed"}}}},
+			ObjectStatus{Health: healthDegraded, Reason: "Failed"}},
+		{"conditionOutdated", map[string]interface{}{"metadata": map[string]interface{}{"generation": int64(3)}, "status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "True", "observedGeneration": int64(2)}}}},
+			ObjectStatus{Health: healthProgressing}},
+		{"phase", map[string]interface{}{"status": map[string]interface{}{"phase": "error"}},
+			ObjectStatus{Health: healthDegraded, Reason: "error"}},
+		{"unmappedPhase", map[string]interface{}{"status": map[string]interface{}{"phase": "DEPLOYING"}},
+			ObjectStatus{Health: healthUnknown, Reason: "DEPLOYING"}},
+		{"nothing", map[string]interface{}{},
+			ObjectStatus{Health: healthUnknown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: tt.object}
+			got := w.Health(obj)
+			if got == nil || *got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+			if !w.MatchHealth(obj, []string{"ready", string(tt.expected.Health)}) || (tt.expected.Health != healthReady && w.MatchHealth(obj, []string{"ready"})) {
+				t.Errorf("Unexpected MatchHealth() result")
+			}
+		})
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{"phase": "READY"}}}
+	w.Decorate(obj)
+	status := obj.Object[DecorationKey].(map[string]interface{})["status"].(map[string]interface{})
+	if status["health"] != "ready" || status["reason"] != "READY" {
+		t.Errorf("Unexpected decoration: %v", status)
+	}
+
+	if _, err := Parse([]byte(testWrapHeader+"status:\n  phases:\n    fine: [OK]\n"), "test"); err == nil {
+		t.Errorf("Expected error on invalid health")
+	}
+}
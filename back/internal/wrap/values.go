@@ -0,0 +1,397 @@
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FieldError report an invalid submitted value
+type FieldError struct {
+	Path    string `yaml:"path" json:"path"` // i.e. 'package.tag' or 'emails[1]'
+	Message string `yaml:"message" json:"message"`
+}
+
+// ValidationError is returned when some submitted values are invalid
+type ValidationError struct {
+	Errors []FieldError `yaml:"errors" json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", fe.Path, fe.Message))
+	}
+	return "invalid values: " + strings.Join(msgs, ", ")
+}
+
+type fieldErrors []FieldError
+
+func (e *fieldErrors) add(path string, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// CheckValues validate submitted field values, by field name. resource is the existing object, if any (nil on creation),
+// exposed as 'resource' to expressions.
+// Fields whose Condition is false are inactive: their values are discarded, and their rules are skipped.
+// Return the normalized values, ready to be provided to the template, and the paths of the inactive fields (to be
+// provided to KeepSecrets()), or a *ValidationError
+func (w *Wrap) CheckValues(values map[string]interface{}, resource *unstructured.Unstructured) (map[string]interface{}, []string, error) {
+	return checkValues(w.Schema.Fields, w.Schema.Validation, values, resource)
+}
+
+// checkValues validate values against a fields list, then against the global validation, if any
+func checkValues(fields []Field, validation *Validation, values map[string]interface{}, resource *unstructured.Unstructured) (map[string]interface{}, []string, error) {
+	root, ok := celValue(values).(map[string]interface{})
+	if !ok {
+		root = map[string]interface{}{}
+	}
+	stripComputed(fields, root)
+	vc := &valueChecker{root: root}
+	if resource != nil {
+		vc.resource = resource.Object
+	}
+	result := checkFields(fields, values, "", vc)
+	computeValues(fields, result, "", vc)
+	if validation != nil && len(vc.errs) == 0 {
+		activation := vc.activation(fields, result, result)
+		vc.validate("", validation, activation)
+	}
+	if len(vc.errs) > 0 {
+		return nil, nil, &ValidationError{Errors: vc.errs}
+	}
+	return result, vc.inactive, nil
+}
+
+// valueChecker hold the state of a CheckValues() run
+type valueChecker struct {
+	errs     fieldErrors
+	root     map[string]interface{} // The submitted values, exposed as 'fields' to expressions
+	resource interface{}            // The existing object, if any. Untyped nil otherwise, to be null in expressions
+	inactive []string               // Paths of the discarded fields
+}
+
+func (vc *valueChecker) add(path string, format string, args ...interface{}) {
+	vc.errs.add(path, format, args...)
+}
+
+// activation expose to Condition and Validation expressions the sibling fields by name (null if not submitted),
+// the root values as 'fields', the existing object as 'resource' (null on creation) and the checked value, if any, as 'self'.
+func (vc *valueChecker) activation(fields []Field, siblings map[string]interface{}, self interface{}) map[string]interface{} {
+	activation := make(map[string]interface{}, len(fields)+3)
+	for idx := range fields {
+		activation[fields[idx].Name] = celValue(siblings[fields[idx].Name])
+	}
+	activation["fields"] = vc.root
+	activation["resource"] = vc.resource
+	activation["self"] = self
+	return activation
+}
+
+// isActive evaluate the field Condition against the submitted values. A field without condition is always active.
+// A condition which can't be evaluated (i.e. 'replicas > 2' while replicas is not set) makes the field inactive.
+// This is not a user error, as the field is not even displayed: it is only logged, at debug level.
+func (vc *valueChecker) isActive(path string, field *Field, activation map[string]interface{}) bool {
+	if field.Condition == "" {
+		return true
+	}
+	result, err := evalCel(field.Condition, activation)
+	if err != nil {
+		slog.Debug("Unable to evaluate condition, field is inactive", "field", path, "condition", field.Condition, "error", err)
+		return false
+	}
+	active, _ := result.(bool)
+	return active
+}
+
+// discard remove the value of an inactive field from the root values, so that expressions don't see it.
+// path is a field path (i.e. 'package.tag' or 'ports[1].name')
+func (vc *valueChecker) discard(path string) {
+	vc.inactive = append(vc.inactive, path)
+	current := vc.root
+	segments := strings.Split(path, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		name, indexes, _ := strings.Cut(segment, "[")
+		value := current[name]
+		if indexes != "" {
+			for _, index := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+				items, ok := value.([]interface{})
+				idx, err := strconv.Atoi(index)
+				if !ok || err != nil || idx < 0 || idx >= len(items) {
+					return
+				}
+				value = items[idx]
+			}
+		}
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		current = m
+	}
+	delete(current, segments[len(segments)-1])
+}
+
+// isInactive tell if the field at path, or one of its parents, is in inactive (as returned by CheckValues())
+func isInactive(inactive []string, path string) bool {
+	for _, p := range inactive {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// validate evaluate a Validation test, and add its message if false
+func (vc *valueChecker) validate(path string, validation *Validation, activation map[string]interface{}) {
+	if validation.Test == "" {
+		return
+	}
+	result, err := evalCel(validation.Test, activation)
+	if err != nil {
+		vc.add(path, "unable to evaluate validation: %v", err)
+		return
+	}
+	if valid, ok := result.(bool); !ok || !valid {
+		message := validation.Message
+		if message == "" {
+			message = "invalid value"
+		}
+		vc.add(path, "%s", message)
+	}
+}
+
+func checkFields(fields []Field, values map[string]interface{}, path string, vc *valueChecker) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	known := make(map[string]bool, len(fields))
+	// Siblings exposed to expressions, without the inactive ones
+	siblings := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		siblings[k] = v
+	}
+	for idx := range fields {
+		if fields[idx].Compute != "" {
+			delete(siblings, fields[idx].Name)
+		}
+	}
+	for idx := range fields {
+		field := &fields[idx]
+		known[field.Name] = true
+		fieldPath := joinFieldPath(path, field.Name)
+		if field.Compute != "" {
+			continue // Submitted value is ignored. See computeValues()
+		}
+		if !vc.isActive(fieldPath, field, vc.activation(fields, siblings, nil)) {
+			delete(siblings, field.Name)
+			vc.discard(fieldPath)
+			continue
+		}
+		value, ok := values[field.Name]
+		if !ok || isEmptyValue(value) {
+			// A missing secret may be kept from the existing object. See KeepSecrets()
+			if field.Required && field.Type.Secret == nil {
+				vc.add(fieldPath, "%s is required", field.Label)
+			}
+			continue
+		}
+		errCount := len(vc.errs)
+		checked := field.Type.check(fieldPath, value, vc)
+		result[field.Name] = checked
+		if field.Validation != nil && len(vc.errs) == errCount {
+			self := celValue(checked)
+			if field.Type.Secret != nil {
+				self = value // The plaintext, before any transform (i.e. for a password policy)
+			}
+			vc.validate(fieldPath, field.Validation, vc.activation(fields, siblings, self))
+		}
+	}
+	unknown := make([]string, 0)
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		vc.add(joinFieldPath(path, name), "unknown field")
+	}
+	return result
+}
+
+// check validate and normalize a submitted value. Errors are accumulated in vc.
+func (t *Type) check(path string, value interface{}, vc *valueChecker) interface{} {
+	switch {
+	case t.Array != nil:
+		return t.Array.check(path, value, vc)
+	case t.Boolean != nil:
+		return t.Boolean.check(path, value, vc)
+	case t.DateTime != nil:
+		return t.DateTime.check(path, value, vc)
+	case t.Duration != nil:
+		return t.Duration.check(path, value, vc)
+	case t.Integer != nil:
+		return t.Integer.check(path, value, vc)
+	case t.Map != nil:
+		return t.Map.check(path, value, vc)
+	case t.Number != nil:
+		return t.Number.check(path, value, vc)
+	case t.Object != nil:
+		return t.Object.check(path, value, vc)
+	case t.Quantity != nil:
+		return t.Quantity.check(path, value, vc)
+	case t.Reference != nil:
+		return t.Reference.check(path, value, vc)
+	case t.Secret != nil:
+		return t.Secret.check(path, value, vc)
+	case t.String != nil:
+		return t.String.check(path, value, vc)
+	}
+	return value
+}
+
+// celValue convert json.Number, which is not handled by CEL, in a nested value
+func celValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = celValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, celValue(item))
+		}
+		return result
+	}
+	return value
+}
+
+// visitValues call fn for each checked value, including object members and array items, with the type describing it.
+func visitValues(fields []Field, values map[string]interface{}, path string, fn func(path string, t *Type, value interface{})) {
+	for idx := range fields {
+		field := &fields[idx]
+		value, ok := values[field.Name]
+		if !ok {
+			continue
+		}
+		visitValue(joinFieldPath(path, field.Name), &field.Type, value, fn)
+	}
+}
+
+func visitValue(path string, t *Type, value interface{}, fn func(path string, t *Type, value interface{})) {
+	switch {
+	case t.Object != nil:
+		if m, ok := value.(map[string]interface{}); ok {
+			visitValues(t.Object.Fields, m, path, fn)
+		}
+	case t.Array != nil:
+		if items, ok := value.([]interface{}); ok {
+			for idx, item := range items {
+				visitValue(fmt.Sprintf("%s[%d]", path, idx), &t.Array.Item.Type, item, fn)
+			}
+		}
+	default:
+		fn(path, t, value)
+	}
+}
+
+// findType return the type of the field matching a path (i.e. 'package.tag'). Array items are transparent.
+func findType(fields []Field, path string) *Type {
+	name, remaining, _ := strings.Cut(path, ".")
+	for idx := range fields {
+		if fields[idx].Name != name {
+			continue
+		}
+		t := &fields[idx].Type
+		if t.Array != nil {
+			t = &t.Array.Item.Type
+		}
+		if remaining == "" {
+			return t
+		}
+		if t.Object == nil {
+			return nil
+		}
+		return findType(t.Object.Fields, remaining)
+	}
+	return nil
+}
+
+func joinFieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	return ok && s == ""
+}
+
+// toInt accept all numeric types resulting from a json or yaml decoding, provided the value is a whole number
+// in the range of int (i.e. 2.0 is accepted, 2.5 is not)
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		if v < math.MinInt || v > math.MaxInt {
+			return 0, false
+		}
+		return int(v), true
+	case float64:
+		return floatToInt(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return toInt(i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return floatToInt(f)
+	}
+	return 0, false
+}
+
+func floatToInt(v float64) (int, bool) {
+	// float64(math.MaxInt) is rounded up to 2^63 (on 64 bits), thus the exclusive upper bound
+	if v != math.Trunc(v) || v < math.MinInt || v >= math.MaxInt {
+		return 0, false
+	}
+	return int(v), true
+}
+
+// toFloat accept all numeric types resulting from a json or yaml decoding
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,264 @@
+package wrap
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWrapHeader = `
+apiVersion: krapper.kubotal.io/v1alpha1
+kind: Wrap
+name: test
+version: v1
+menuMode: grid
+source:
+  apiVersion: v1
+  kind: ConfigMap
+`
+
+func parseTestWrap(t *testing.T, body string) *Wrap {
+	t.Helper()
+	w, err := Parse([]byte(testWrapHeader+body), "test")
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if w == nil {
+		t.Fatal("Parse() returned no wrap")
+	}
+	return w
+}
+
+// decodeValues mimic the values decoding performed by the http handler
+func decodeValues(t *testing.T, payload string) map[string]interface{} {
+	t.Helper()
+	var values map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func checkErrorPaths(t *testing.T, err error, expected ...string) {
+	t.Helper()
+	if len(expected) == 0 {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return
+	}
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	paths := make([]string, 0, len(validationError.Errors))
+	for _, fe := range validationError.Errors {
+		paths = append(paths, fe.Path)
+	}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected errors on %v, got %v", expected, validationError.Errors)
+	}
+}
+
+const enumSchema = `
+schema:
+  fields:
+    - name: color
+      string:
+        enum:
+          - red
+          - value: green
+            label: Green color
+    - name: size
+      integer:
+        uiComponent: radio
+        enum: [1, 2, 3]
+    - name: tags
+      array:
+        uiComponent: multiSelect
+        item:
+          string:
+            enum: [a, b]
+`
+
+func TestEnumGroom(t *testing.T) {
+	w := parseTestWrap(t, enumSchema)
+	color := w.Schema.Fields[0].Type.String
+	if color.UiComponent != "select" {
+		t.Errorf("Expected 'select' default UiComponent, got %s", color.UiComponent)
+	}
+	if color.Enum[0].Label != "red" || color.Enum[1].Label != "Green color" {
+		t.Errorf("Unexpected enum labels: %v", color.Enum)
+	}
+
+	failing := map[string]string{
+		"default not in enum": `
+schema:
+  fields:
+    - name: color
+      string:
+        default: blue
+        enum: [red, green]
+`,
+		"select without enum": `
+schema:
+  fields:
+    - name: size
+      integer:
+        uiComponent: select
+`,
+		"multiSelect without enum": `
+schema:
+  fields:
+    - name: tags
+      array:
+        uiComponent: multiSelect
+        item:
+          string: {}
+`,
+	}
+	for name, body := range failing {
+		t.Run(name, func(t *testing.T) {
+			w, err := Parse([]byte(testWrapHeader+body), "test")
+			if err == nil {
+				t.Errorf("Expected an error, got %v", w)
+			}
+		})
+	}
+}
+
+func TestEnumValues(t *testing.T) {
+	w := parseTestWrap(t, enumSchema)
+
+	values, _, err := w.CheckValues(decodeValues(t, `{"color": "green", "size": 2, "tags": ["a", "b"]}`), nil)
+	checkErrorPaths(t, err)
+	if values["size"] != 2 {
+		t.Errorf("Expected size to be normalized to int 2, got %#v", values["size"])
+	}
+
+	_, _, err = w.CheckValues(decodeValues(t, `{"color": "blue", "size": 4, "tags": ["a", "c"], "other": 1}`), nil)
+	checkErrorPaths(t, err, "color", "size", "tags[1]", "other")
+
+	// A multiSelect is a set
+	_, _, err = w.CheckValues(decodeValues(t, `{"tags": ["a", "b", "a"]}`), nil)
+	checkErrorPaths(t, err, "tags[2]")
+
+	values, _, err = w.CheckValues(decodeValues(t, `{"size": 2.0}`), nil)
+	checkErrorPaths(t, err)
+	if values["size"] != 2 {
+		t.Errorf("Expected 2.0 to be accepted as int 2, got %#v", values["size"])
+	}
+	_, _, err = w.CheckValues(decodeValues(t, `{"size": 2.5}`), nil)
+	checkErrorPaths(t, err, "size")
+}
+
+func TestToInt(t *testing.T) {
+	for _, tc := range []struct {
+		value    interface{}
+		expected int
+		ok       bool
+	}{
+		{value: 3, expected: 3, ok: true},
+		{value: int64(-4), expected: -4, ok: true},
+		{value: 5.0, expected: 5, ok: true},
+		{value: 5.5},
+		{value: 1e19},
+		{value: -1e19},
+		{value: json.Number("6"), expected: 6, ok: true},
+		{value: json.Number("7.0"), expected: 7, ok: true},
+		{value: json.Number("7.5")},
+		{value: json.Number("1e2"), expected: 100, ok: true},
+		{value: json.Number("99999999999999999999")},
+		{value: "8"},
+	} {
+		got, ok := toInt(tc.value)
+		if ok != tc.ok || got != tc.expected {
+			t.Errorf("toInt(%#v): expected (%d, %v), got (%d, %v)", tc.value, tc.expected, tc.ok, got, ok)
+		}
+	}
+}
+
+func TestDateTimeAndQuantity(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: expiry
+      dateTime:
+        min: "2025-01-01T00:00:00Z"
+        max: "2030-01-01T00:00:00Z"
+    - name: memory
+      quantity:
+        units: [Mi, Gi]
+        max: 4Gi
+    - name: cpu
+      quantity:
+        units: ["", m]
+        min: 100m
+`)
+	values, _, err := w.CheckValues(decodeValues(t, `{"expiry": "2026-03-01T10:00:00+02:00", "memory": "512Mi", "cpu": 2}`), nil)
+	checkErrorPaths(t, err)
+	if values["cpu"] != "2" {
+		t.Errorf("Expected cpu as string, got %#v", values["cpu"])
+	}
+	_, _, err = w.CheckValues(decodeValues(t, `{"expiry": "2024-12-31T23:59:59Z", "memory": "5Gi", "cpu": "50m"}`), nil)
+	checkErrorPaths(t, err, "expiry", "memory", "cpu")
+	_, _, err = w.CheckValues(decodeValues(t, `{"expiry": "2026-03-01", "memory": "1G", "cpu": "1x"}`), nil)
+	checkErrorPaths(t, err, "expiry", "memory", "cpu")
+	// Exponent forms have no unit
+	_, _, err = w.CheckValues(decodeValues(t, `{"memory": "1e9", "cpu": "2e0"}`), nil)
+	checkErrorPaths(t, err, "memory")
+	_, _, err = w.CheckValues(decodeValues(t, `{"memory": "1.5Gi", "cpu": "+1e3"}`), nil)
+	checkErrorPaths(t, err)
+
+	_, err = Parse([]byte(testWrapHeader+`
+schema:
+  fields:
+    - name: memory
+      quantity:
+        min: 2Gi
+        default: 1Gi
+`), "test")
+	if err == nil {
+		t.Error("Expected an error on out of range default")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	w := parseTestWrap(t, `
+schema:
+  fields:
+    - name: timeout
+      duration:
+        default: 5m
+        min: 30s
+        max: 1h
+`)
+	field := w.Schema.Fields[0].Type.Duration
+	if field.Default != Duration(5*time.Minute) {
+		t.Errorf("Unexpected default: %v", time.Duration(field.Default))
+	}
+	data, err := json.Marshal(field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"default":"5m","min":"30s","max":"1h"`) {
+		t.Errorf("Unexpected json: %s", data)
+	}
+	values, _, err := w.CheckValues(decodeValues(t, `{"timeout": "90s"}`), nil)
+	checkErrorPaths(t, err)
+	if values["timeout"] != "90s" {
+		t.Errorf("Unexpected value: %v", values["timeout"])
+	}
+	_, _, err = w.CheckValues(decodeValues(t, `{"timeout": "10s"}`), nil)
+	checkErrorPaths(t, err, "timeout")
+	_, _, err = w.CheckValues(decodeValues(t, `{"timeout": "2h"}`), nil)
+	checkErrorPaths(t, err, "timeout")
+	_, _, err = w.CheckValues(decodeValues(t, `{"timeout": 300}`), nil)
+	checkErrorPaths(t, err, "timeout")
+	_, _, err = w.CheckValues(decodeValues(t, `{"timeout": "5 minutes"}`), nil)
+	checkErrorPaths(t, err, "timeout")
+}
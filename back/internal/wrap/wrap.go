@@ -1,9 +1,16 @@
 package wrap
 
 import (
+	"context"
 	"fmt"
+	"krapper/internal/k8s"
 	"krapper/internal/misc"
 	"strings"
+	"text/template"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 type Cel string
@@ -36,6 +43,12 @@ type Wrap struct {
 	Label string `yaml:"label" json:"label"`
 	// optional
 	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Optional. Icon name, interpreted by the front (i.e. 'pi pi-users')
+	Icon string `yaml:"icon,omitempty" json:"icon,omitempty"`
+	// Optional. Used to group wraps in the menu
+	Category string `yaml:"category,omitempty" json:"category,omitempty"`
+	// Optional. Catalog is sorted by order, then by label
+	Order int `yaml:"order,omitempty" json:"order,omitempty"`
 	// Required
 	MenuMode MenuMode `yaml:"menuMode,omitempty" json:"menuMode,omitempty"`
 
@@ -49,12 +62,13 @@ type Wrap struct {
 		ClusterScoped bool              `yaml:"clusterScoped" json:"clusterScoped"`
 	} `yaml:"source" json:"source"`
 
-	Operations struct {
-		View   bool `yaml:"view" json:"view"`
-		Create bool `yaml:"create" json:"create"`
-		Update bool `yaml:"update" json:"update"`
-		Delete bool `yaml:"delete" json:"delete"`
-	} `yaml:"operations" json:"operations"`
+	Operations Operations `yaml:"operations" json:"operations"`
+
+	// Optional. Users and groups allowed per operation
+	Access Access `yaml:"access,omitempty" json:"access,omitempty"`
+
+	// Optional. Normalized health of the objects, reported in list and get responses
+	Status *Status `yaml:"status,omitempty" json:"status,omitempty"`
 
 	Schema struct {
 		Validation *Validation `yaml:"validation,omitempty" json:"validation,omitempty"`
@@ -63,6 +77,22 @@ type Wrap struct {
 	} `yaml:"schema" json:"schema"`
 
 	Template WrTemplate `yaml:"template,omitempty" json:"template,omitempty"`
+
+	// Optional. Operations on existing objects, beyond CRUD
+	Actions []Action `yaml:"actions,omitempty" json:"actions,omitempty"`
+
+	// Optional. Objects linked to the wrapped ones, for drill-down
+	Related []Related `yaml:"related,omitempty" json:"related,omitempty"`
+
+	template *template.Template
+	i18n     *localized[Wrap]
+}
+
+type Operations struct {
+	View   bool `yaml:"view" json:"view"`
+	Create bool `yaml:"create" json:"create"`
+	Update bool `yaml:"update" json:"update"`
+	Delete bool `yaml:"delete" json:"delete"`
 }
 
 var _ valuePathProvider = &Wrap{}
@@ -71,6 +101,43 @@ func (w *Wrap) GetValuePath() string {
 	return w.Schema.ValuePath
 }
 
+// TargetNamespace return the namespace of the object handled by the wrap: none for cluster scoped resources,
+// the source one if fixed, and the requested one otherwise.
+// Used by all endpoints, so reference options and submit time checks resolve in the same namespace.
+func (w *Wrap) TargetNamespace(requested string) string {
+	if w.Source.ClusterScoped {
+		return ""
+	}
+	if w.Source.Namespace != "" {
+		return w.Source.Namespace
+	}
+	return requested
+}
+
+// Selects tell if obj matches the source selector, so belongs to the wrap
+func (w *Wrap) Selects(obj *unstructured.Unstructured) bool {
+	labels := obj.GetLabels()
+	for k, v := range w.Source.Selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// GetObject get the object of the wrap with this name. namespace is the one returned by TargetNamespace().
+// An object not matching the source selector belongs to another wrap, and is reported as not found.
+func (w *Wrap) GetObject(ctx context.Context, client k8s.Client, namespace string, name string) (*unstructured.Unstructured, error) {
+	obj, err := client.GetResource(ctx, w.Source.ApiVersion, w.Source.Kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if !w.Selects(obj) {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: w.Source.Kind}, name)
+	}
+	return obj, nil
+}
+
 func (w *Wrap) Groom() error {
 	if w.ApiVersion != "krapper.kubotal.io/v1alpha1" {
 		return fmt.Errorf("invalid api version: %s", w.ApiVersion)
@@ -101,6 +168,13 @@ func (w *Wrap) Groom() error {
 		return fmt.Errorf("no kind defined for source")
 	}
 
+	if w.Status != nil {
+		err := w.Status.groom()
+		if err != nil {
+			return fmt.Errorf("invalid status: %v", err)
+		}
+	}
+
 	if w.Schema.Validation != nil {
 		err := w.Schema.Validation.groom()
 		if err != nil {
@@ -114,6 +188,43 @@ func (w *Wrap) Groom() error {
 			return fmt.Errorf("field '%s': %v", w.Schema.Fields[idx].Name, err)
 		}
 	}
+	if _, err := computeOrder(w.Schema.Fields, true); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(w.Actions))
+	for idx := range w.Actions {
+		action := &w.Actions[idx]
+		err := action.groom(w.Name)
+		if err != nil {
+			return fmt.Errorf("action '%s': %v", action.Name, err)
+		}
+		if names[action.Name] {
+			return fmt.Errorf("duplicated action '%s'", action.Name)
+		}
+		names[action.Name] = true
+	}
+
+	names = make(map[string]bool, len(w.Related))
+	for idx := range w.Related {
+		related := &w.Related[idx]
+		err := related.groom()
+		if err != nil {
+			return err
+		}
+		if names[related.Name] {
+			return fmt.Errorf("duplicated related '%s'", related.Name)
+		}
+		names[related.Name] = true
+	}
+
+	if w.Template != "" {
+		tmpl, err := parseTemplate(w.Name, w.Template)
+		if err != nil {
+			return fmt.Errorf("invalid template: %v", err)
+		}
+		w.template = tmpl
+	}
 	return nil
 }
 
@@ -131,26 +242,6 @@ func (v *Validation) groom() error {
 	return validCel(v.Test)
 }
 
-func validCel(exp Cel) error {
-	if exp == "" {
-		return nil
-	}
-	// TODO
-	//env, err := cel.NewEnv()
-	//if err != nil {
-	//	return err
-	//}
-	//ast, issues := env.Compile(string(exp))
-	//if issues != nil && issues.Err() != nil {
-	//	return issues.Err()
-	//}
-	//_, err = env.Program(ast)
-	//if err != nil {
-	//	return err
-	//}
-	return nil
-}
-
 var alignmentSet = map[Alignment]bool{leftAlign: true, centerAlign: true, rightAlign: true}
 
 func validAlignment(a Alignment) error {
@@ -0,0 +1,119 @@
+package wrapstore
+
+import (
+	"context"
+	"fmt"
+	"krapper/internal/k8s"
+	"krapper/internal/wrap"
+	"log/slog"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ClusterWrapLabel must be set to "true" on ConfigMaps hosting wrap definitions.
+// Each data entry with a '.yaml' suffix is handled as a wrap file.
+const ClusterWrapLabel = "krapper.kubotal.io/wrap"
+
+// clusterSource load wraps from labeled ConfigMaps. Changes are detected by polling.
+type clusterSource struct {
+	ctx       context.Context
+	client    k8s.Client
+	namespace string
+	interval  time.Duration
+	logger    *slog.Logger
+	sink      *sink
+	contents  map[string]string // location -> content
+}
+
+var _ Source = &clusterSource{}
+
+// NewClusterSource build a source polling the ConfigMaps of namespace, until ctx is done.
+// The namespace is required: wraps templates are applied with krapper credentials, so whoever can
+// create a ConfigMap in this namespace must be trusted.
+func NewClusterSource(ctx context.Context, client k8s.Client, namespace string, interval time.Duration, logger *slog.Logger) (Source, error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("a namespace is required for cluster wraps")
+	}
+	return &clusterSource{
+		ctx:       ctx,
+		client:    client,
+		namespace: namespace,
+		interval:  interval,
+		logger:    logger,
+		contents:  make(map[string]string),
+	}, nil
+}
+
+func (c *clusterSource) kind() string {
+	return "cluster"
+}
+
+func (c *clusterSource) start(sink *sink) error {
+	c.sink = sink
+	// A cluster not reachable at startup is not fatal, as it may be fixed later.
+	if err := c.sync(); err != nil {
+		c.logger.Warn("Unable to load wraps from cluster", "error", err)
+	}
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.sync(); err != nil {
+					c.logger.Warn("Unable to load wraps from cluster", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *clusterSource) sync() error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.interval)
+	defer cancel()
+	list, err := c.client.ListResources(ctx, "v1", "ConfigMap", c.namespace, map[string]string{ClusterWrapLabel: "true"})
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, cm := range list.Items {
+		data, _, _ := unstructured.NestedStringMap(cm.Object, "data")
+		for key, content := range data {
+			if !strings.HasSuffix(key, ".yaml") {
+				continue
+			}
+			location := fmt.Sprintf("%s/%s/%s", cm.GetNamespace(), cm.GetName(), key)
+			seen[location] = true
+			if previous, ok := c.contents[location]; ok && previous == content {
+				continue
+			}
+			doc, err := wrap.ParseDocument([]byte(content), location)
+			if err != nil {
+				// Not cached, to be retried on next sync. A previous version must not stay active
+				c.logger.Warn("Failed to load wrap", "location", location, "error", err)
+				delete(c.contents, location)
+				c.sink.remove(location)
+				continue
+			}
+			c.contents[location] = content
+			if doc == nil {
+				c.sink.remove(location)
+				c.logger.Warn("ConfigMap entry is not a krapper definition, skipping", "location", location)
+				continue
+			}
+			c.sink.update(location, doc)
+		}
+	}
+	for location := range c.contents {
+		if !seen[location] {
+			delete(c.contents, location)
+			c.sink.remove(location)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package wrapstore
+
+import (
+	"context"
+	"krapper/internal/k8s"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// configMapClient serve a fixed list of ConfigMaps. Other k8s.Client methods are not used by the cluster source
+type configMapClient struct {
+	k8s.Client
+	data map[string]interface{}
+}
+
+func (c *configMapClient) ListResources(_ context.Context, _, _, namespace string, _ map[string]string) (*unstructured.UnstructuredList, error) {
+	cm := unstructured.Unstructured{Object: map[string]interface{}{"data": c.data}}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace(namespace)
+	cm.SetName("wraps")
+	return &unstructured.UnstructuredList{Items: []unstructured.Unstructured{cm}}, nil
+}
+
+func TestClusterSource(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	client := &configMapClient{data: map[string]interface{}{"users.yaml": testWrap("users", "v1")}}
+	if _, err := NewClusterSource(context.Background(), client, "", time.Hour, logger); err == nil {
+		t.Fatalf("Expected an error without namespace")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source, err := NewClusterSource(ctx, client, "krapper", time.Hour, logger)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ws, err := New(logger, source)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	cs := source.(*clusterSource)
+	if w := ws.GetWrap("users"); w == nil || w.Version != "v1" {
+		t.Fatalf("Expected users wrap v1")
+	}
+
+	// A broken version must not leave the previous one active
+	client.data["users.yaml"] = testWrap("users", "v2") + "unknownField: true\n"
+	if err := cs.sync(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ws.GetWrap("users") != nil {
+		t.Errorf("Expected broken wrap to be removed")
+	}
+
+	// Same content is retried
+	if err := cs.sync(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ws.GetWrap("users") != nil {
+		t.Errorf("Expected broken wrap to stay removed")
+	}
+	client.data["users.yaml"] = testWrap("users", "v3")
+	if err := cs.sync(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if w := ws.GetWrap("users"); w == nil || w.Version != "v3" {
+		t.Errorf("Expected fixed wrap to be loaded")
+	}
+}
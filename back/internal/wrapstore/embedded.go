@@ -0,0 +1,53 @@
+package wrapstore
+
+import (
+	"io/fs"
+	"krapper/internal/wrap"
+	"log/slog"
+	"strings"
+)
+
+// embeddedSource load all '*.yaml' files of a filesystem compiled into the binary. No change watching.
+type embeddedSource struct {
+	fsys   fs.FS
+	logger *slog.Logger
+}
+
+var _ Source = &embeddedSource{}
+
+func NewEmbeddedSource(fsys fs.FS, logger *slog.Logger) Source {
+	return &embeddedSource{
+		fsys:   fsys,
+		logger: logger,
+	}
+}
+
+func (e *embeddedSource) kind() string {
+	return "embedded"
+}
+
+func (e *embeddedSource) start(sink *sink) error {
+	return fs.WalkDir(e.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".yaml") {
+			return nil
+		}
+		data, err := fs.ReadFile(e.fsys, path)
+		if err != nil {
+			return err
+		}
+		doc, err := wrap.ParseDocument(data, path)
+		if err != nil {
+			e.logger.Warn("Failed to load wrap", "path", path, "error", err)
+			return nil
+		}
+		if doc == nil {
+			e.logger.Warn("File is not a krapper definition, skipping", "path", path)
+			return nil
+		}
+		sink.update(path, doc)
+		return nil
+	})
+}
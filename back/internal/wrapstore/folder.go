@@ -0,0 +1,143 @@
+package wrapstore
+
+import (
+	"fmt"
+	"io/fs"
+	"krapper/internal/wrap"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/fsnotify.v1"
+)
+
+// folderSource load all '*.yaml' files of a folder, recursively, and watch them for changes.
+type folderSource struct {
+	baseDir string
+	watcher *fsnotify.Watcher
+	logger  *slog.Logger
+	sink    *sink
+}
+
+var _ Source = &folderSource{}
+
+func NewFolderSource(path string, logger *slog.Logger) (Source, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return &folderSource{
+		baseDir: absPath,
+		logger:  logger,
+	}, nil
+}
+
+func (f *folderSource) kind() string {
+	return "folder"
+}
+
+func (f *folderSource) start(sink *sink) error {
+	f.sink = sink
+
+	// 1. Initial Load
+	if err := f.loadAll(); err != nil {
+		return err
+	}
+
+	// 2. Setup Watcher
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	f.watcher = watcher
+
+	// Add all subdirectories to watcher
+	if err := f.watchRecursive(f.baseDir); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+
+	// 3. Start Watch Loop
+	go f.watchLoop()
+
+	return nil
+}
+
+func (f *folderSource) loadAll() error {
+	return filepath.WalkDir(f.baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".yaml") {
+			f.updateFile(path)
+		}
+		return nil
+	})
+}
+
+func (f *folderSource) updateFile(path string) {
+	// Load the file
+	doc, err := wrap.LoadDocument(path)
+	if err != nil {
+		f.logger.Warn("Failed to load wrap", "path", path, "error", err)
+		return
+	}
+	if doc == nil {
+		f.logger.Warn("File is not a krapper definition, skipping", "path", path)
+		return
+	}
+	f.sink.update(path, doc)
+}
+
+func (f *folderSource) watchRecursive(path string) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return f.watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+func (f *folderSource) watchLoop() {
+	defer func() { _ = f.watcher.Close() }()
+
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+
+			// Handle new directories (Watcher doesn't recursively watch new dirs automatically)
+			// But note: fsnotify events order for mkdir might vary.
+			// Ideally we check if it is a directory on CREATE.
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				stat, err := os.Stat(event.Name)
+				if err == nil && stat.IsDir() {
+					_ = f.watcher.Add(event.Name)
+				}
+			}
+
+			if strings.HasSuffix(event.Name, ".yaml") {
+				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
+					f.updateFile(event.Name)
+				} else if event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename {
+					f.sink.remove(event.Name)
+				}
+			}
+
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			f.logger.Error("watcher error", "error", err)
+		}
+	}
+}
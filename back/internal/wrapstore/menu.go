@@ -0,0 +1,123 @@
+package wrapstore
+
+import (
+	"krapper/internal/wrap"
+	"sort"
+)
+
+// MenuNode is either a section (with children) or a leaf referencing a wrap
+type MenuNode struct {
+	Label    string      `yaml:"label" json:"label"`
+	Icon     string      `yaml:"icon,omitempty" json:"icon,omitempty"`
+	Wrap     string      `yaml:"wrap,omitempty" json:"wrap,omitempty"` // Wrap name, for a leaf
+	Children []*MenuNode `yaml:"children,omitempty" json:"children,omitempty"`
+}
+
+// buildMenu merge all menus, by increasing order.
+// Wraps not referenced by any menu are appended, grouped in a section per category.
+// items must be sorted. Menus are localized for the locales.
+func (s *store) buildMenu(items []CatalogItem, locales []string) []*MenuNode {
+	menus := make([]*wrap.Menu, 0, len(s.elected["menu"]))
+	for _, e := range s.elected["menu"] {
+		menus = append(menus, e.doc.Menu.Localize(locales))
+	}
+	sort.Slice(menus, func(i, j int) bool {
+		if menus[i].Order != menus[j].Order {
+			return menus[i].Order < menus[j].Order
+		}
+		return menus[i].Name < menus[j].Name
+	})
+
+	itemByName := make(map[string]*CatalogItem, len(items))
+	for idx := range items {
+		itemByName[items[idx].Name] = &items[idx]
+	}
+	referenced := make(map[string]bool)
+
+	root := make([]*MenuNode, 0)
+	for _, menu := range menus {
+		root = append(root, s.buildMenuNodes(menu.Items, itemByName, referenced)...)
+	}
+
+	for idx := range items {
+		item := &items[idx]
+		if referenced[item.Name] {
+			continue
+		}
+		leaf := &MenuNode{Label: item.Label, Icon: item.Icon, Wrap: item.Name}
+		if item.Category == "" {
+			root = append(root, leaf)
+			continue
+		}
+		var section *MenuNode
+		for _, node := range root {
+			if node.Wrap == "" && node.Label == item.Category {
+				section = node
+				break
+			}
+		}
+		if section == nil {
+			section = &MenuNode{Label: item.Category}
+			root = append(root, section)
+		}
+		section.Children = append(section.Children, leaf)
+	}
+	return root
+}
+
+// buildMenuNodes convert menu items of one level. Items are stable sorted by order.
+// References to unknown (or hidden to the user) wraps and resulting empty sections are dropped. See reportUnknownWraps()
+func (s *store) buildMenuNodes(menuItems []wrap.MenuItem, itemByName map[string]*CatalogItem, referenced map[string]bool) []*MenuNode {
+	sorted := make([]*wrap.MenuItem, len(menuItems))
+	for idx := range menuItems {
+		sorted[idx] = &menuItems[idx]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Order < sorted[j].Order
+	})
+	nodes := make([]*MenuNode, 0, len(sorted))
+	for _, menuItem := range sorted {
+		if menuItem.Wrap != "" {
+			item, ok := itemByName[menuItem.Wrap]
+			if !ok {
+				continue
+			}
+			referenced[item.Name] = true
+			node := &MenuNode{Label: menuItem.Label, Icon: menuItem.Icon, Wrap: item.Name}
+			if node.Label == "" {
+				node.Label = item.Label
+			}
+			if node.Icon == "" {
+				node.Icon = item.Icon
+			}
+			nodes = append(nodes, node)
+			continue
+		}
+		children := s.buildMenuNodes(menuItem.Items, itemByName, referenced)
+		if len(children) == 0 {
+			continue
+		}
+		nodes = append(nodes, &MenuNode{Label: menuItem.Label, Icon: menuItem.Icon, Children: children})
+	}
+	return nodes
+}
+
+// reportUnknownWraps log the menu entries referencing an unknown wrap. Called once per store update, as menus are built per request.
+func (s *store) reportUnknownWraps() {
+	for _, e := range s.elected["menu"] {
+		s.reportUnknownMenuWraps(e.doc.Menu.Name, e.doc.Menu.Items)
+	}
+}
+
+func (s *store) reportUnknownMenuWraps(menuName string, menuItems []wrap.MenuItem) {
+	for idx := range menuItems {
+		menuItem := &menuItems[idx]
+		if menuItem.Wrap == "" {
+			s.reportUnknownMenuWraps(menuName, menuItem.Items)
+			continue
+		}
+		if e := s.elected["wrap"][menuItem.Wrap]; e == nil || e.wrap == nil {
+			s.logger.Warn("Menu reference an unknown wrap", "menu", menuName, "wrap", menuItem.Wrap)
+		}
+	}
+}
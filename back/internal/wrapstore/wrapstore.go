@@ -2,77 +2,109 @@ package wrapstore
 
 import (
 	"fmt"
-	"io/fs"
+	"krapper/internal/auth"
 	"krapper/internal/wrap"
 	"log/slog"
-	"os"
-	"path/filepath"
-	"strings"
+	"slices"
+	"sort"
 	"sync"
-
-	"gopkg.in/fsnotify.v1"
 )
 
+// Origin records where a wrap definition has been loaded from
+type Origin struct {
+	Source   string `yaml:"source" json:"source"`     // 'folder', 'embedded' or 'cluster'
+	Location string `yaml:"location" json:"location"` // File path, embedded file path or configMap 'namespace/name/key'
+}
+
 type CatalogItem struct {
-	Name     string        `yaml:"name" json:"name"`
-	Label    string        `yaml:"label" json:"label"`
-	MenuMode wrap.MenuMode `yaml:"menuMode" json:"menuMode"`
+	Name        string          `yaml:"name" json:"name"`
+	Label       string          `yaml:"label" json:"label"`
+	Description string          `yaml:"description,omitempty" json:"description,omitempty"`
+	Version     string          `yaml:"version" json:"version"`
+	Icon        string          `yaml:"icon,omitempty" json:"icon,omitempty"`
+	Category    string          `yaml:"category,omitempty" json:"category,omitempty"`
+	Order       int             `yaml:"order" json:"order"`
+	MenuMode    wrap.MenuMode   `yaml:"menuMode" json:"menuMode"`
+	Operations  wrap.Operations `yaml:"operations" json:"operations"`
+	Origin      Origin          `yaml:"origin" json:"origin"`
 }
 
 type Catalog struct {
-	Wraps []CatalogItem `yaml:"wraps" json:"wraps"`
+	Wraps []CatalogItem `yaml:"wraps" json:"wraps"` // Flat form
+	Menu  []*MenuNode   `yaml:"menu" json:"menu"`   // Tree form
 }
 
 type WrapStore interface {
 	GetCatalog() *Catalog
+	// GetLocalizedCatalog return the catalog with texts in the first available of the locales (by decreasing preference)
+	GetLocalizedCatalog(locales []string) *Catalog
+	// GetUserCatalog return the localized catalog, restricted to the wraps usable by the identity.
+	// Catalog operations are the ones allowed to the identity
+	GetUserCatalog(locales []string, identity *auth.Identity) *Catalog
 	GetWrap(name string) *wrap.Wrap
 }
 
+// Source provides wrap definitions to the store.
+type Source interface {
+	// kind is recorded as Origin.Source for each provided wrap
+	kind() string
+	// start must perform the initial load synchronously, then may watch for changes in the background.
+	start(sink *sink) error
+}
+
+type entryKey struct {
+	source   int // Index in store.sources
+	location string
+}
+
+type entry struct {
+	key    entryKey
+	doc    *wrap.Document
+	origin Origin
+	// For wrap entries, the effective wrap (Nil if a composite one can't be resolved), and the documents it depends on
+	wrap *wrap.Wrap
+	deps []string
+}
+
+// kind and name of the hosted document. Collisions are handled per kind
+func (e *entry) name() (string, string) {
+	switch {
+	case e.doc.Menu != nil:
+		return "menu", e.doc.Menu.Name
+	case e.doc.Fragment != nil:
+		return "fragment", e.doc.Fragment.Name
+	case e.doc.Composite != nil:
+		return "wrap", e.doc.Composite.Name
+	}
+	return "wrap", e.doc.Wrap.Name
+}
+
 type store struct {
 	mu      sync.RWMutex
-	wraps   map[string]*wrap.Wrap
+	sources []Source
+	entries map[entryKey]*entry
+	elected map[string]map[string]*entry // Winning entries, by kind ('wrap', 'menu' or 'fragment'), then by name
 	catalog *Catalog
-	files   map[string]string // filePath -> wrapName
-	watcher *fsnotify.Watcher
 	logger  *slog.Logger
-	baseDir string
 }
 
-func New(path string, logger *slog.Logger) (WrapStore, error) {
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %w", err)
-	}
-
+// New build a WrapStore aggregating all provided sources.
+// Sources are provided by increasing precedence: On name collision, the wrap (or menu) from the last source wins.
+// Inside a source, the one with the lowest location (In lexical order) wins.
+func New(logger *slog.Logger, sources ...Source) (WrapStore, error) {
 	s := &store{
-		wraps:   make(map[string]*wrap.Wrap),
-		files:   make(map[string]string),
+		sources: sources,
+		entries: make(map[entryKey]*entry),
+		elected: make(map[string]map[string]*entry),
 		logger:  logger,
-		baseDir: absPath,
-	}
-
-	// 1. Initial Load
-	if err := s.loadAll(); err != nil {
-		return nil, err
 	}
 	s.rebuildCatalog()
 
-	// 2. Setup Watcher
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create watcher: %w", err)
-	}
-	s.watcher = watcher
-
-	// Add all subdirectories to watcher
-	if err := s.watchRecursive(absPath); err != nil {
-		_ = watcher.Close()
-		return nil, err
+	for idx, source := range sources {
+		if err := source.start(&sink{store: s, source: idx}); err != nil {
+			return nil, fmt.Errorf("unable to load wraps from %s source: %w", source.kind(), err)
+		}
 	}
-
-	// 3. Start Watch Loop
-	go s.watchLoop()
-
 	return s, nil
 }
 
@@ -82,126 +114,186 @@ func (s *store) GetCatalog() *Catalog {
 	return s.catalog
 }
 
+func (s *store) GetLocalizedCatalog(locales []string) *Catalog {
+	if len(locales) == 0 {
+		return s.GetCatalog()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.buildCatalog(locales, nil)
+}
+
+func (s *store) GetUserCatalog(locales []string, identity *auth.Identity) *Catalog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.buildCatalog(locales, identity)
+}
+
 func (s *store) GetWrap(name string) *wrap.Wrap {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.wraps[name]
+	if e, ok := s.elected["wrap"][name]; ok {
+		return e.wrap
+	}
+	return nil
 }
 
-func (s *store) loadAll() error {
-	return filepath.WalkDir(s.baseDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if strings.HasSuffix(d.Name(), ".yaml") {
-			s.updateFile(path)
-		}
-		return nil
-	})
+// sink is the handle provided to a Source to feed the store.
+type sink struct {
+	store  *store
+	source int
+}
+
+func (sk *sink) update(location string, doc *wrap.Document) {
+	sk.store.update(entryKey{source: sk.source, location: location}, doc)
 }
 
-func (s *store) updateFile(path string) {
+func (sk *sink) remove(location string) {
+	sk.store.remove(entryKey{source: sk.source, location: location})
+}
+
+func (s *store) update(key entryKey, doc *wrap.Document) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Load the file
-	w, err := wrap.Load(path)
-	if err != nil {
-		s.logger.Warn("Failed to load wrap", "path", path, "error", err)
-		return
-	}
-	if w == nil {
-		s.logger.Warn("File is not a wrap, skipping", "path", path)
-		return
+	e := &entry{
+		key: key,
+		doc: doc,
+		origin: Origin{
+			Source:   s.sources[key.source].kind(),
+			Location: key.location,
+		},
 	}
+	s.entries[key] = e
+	kind, name := e.name()
+	s.logger.Info("Loaded "+kind, "name", name, "source", e.origin.Source, "location", e.origin.Location)
+	s.resolve(kind, name)
 
-	// Check if this file was previously associated with a different wrap name (unlikely but possible if name changed in file)
-	if oldName, ok := s.files[path]; ok {
-		if oldName != w.Name {
-			delete(s.wraps, oldName)
+	if winner := s.elected[kind][name]; winner != e {
+		s.logger.Warn("Name collision. Shadowed by another definition", "kind", kind, "name", name, "source", e.origin.Source, "location", e.origin.Location, "activeSource", winner.origin.Source, "activeLocation", winner.origin.Location)
+	} else {
+		for _, other := range s.entries {
+			if otherKind, otherName := other.name(); other != e && otherKind == kind && otherName == name {
+				s.logger.Warn("Name collision. Shadowing another definition", "kind", kind, "name", name, "source", e.origin.Source, "location", e.origin.Location, "shadowedSource", other.origin.Source, "shadowedLocation", other.origin.Location)
+			}
 		}
 	}
-
-	s.wraps[w.Name] = w
-	s.files[path] = w.Name
-	s.logger.Info("Loaded wrap", "name", w.Name, "path", path)
-	s.rebuildCatalog()
 }
 
-func (s *store) removeFile(path string) {
+func (s *store) remove(key entryKey) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if name, ok := s.files[path]; ok {
-		delete(s.wraps, name)
-		delete(s.files, path)
-		s.rebuildCatalog()
+	if e, ok := s.entries[key]; ok {
+		delete(s.entries, key)
+		kind, name := e.name()
+		s.logger.Info("Removed "+kind, "name", name, "source", e.origin.Source, "location", e.origin.Location)
+		s.resolve(kind, name)
 	}
 }
 
-func (s *store) rebuildCatalog() {
-	catalog := &Catalog{
-		Wraps: make([]CatalogItem, 0, len(s.wraps)),
+// resolve elects, for each kind and name, the entry with the highest precedence. Then build composite wraps and the catalog.
+// changedKind and changedName identify the modified document, to report the wraps depending on it.
+func (s *store) resolve(changedKind string, changedName string) {
+	elected := map[string]map[string]*entry{
+		"wrap":     make(map[string]*entry),
+		"menu":     make(map[string]*entry),
+		"fragment": make(map[string]*entry),
 	}
-
-	for _, w := range s.wraps {
-		catalog.Wraps = append(catalog.Wraps, CatalogItem{
-			Name:     w.Name,
-			Label:    w.Label,
-			MenuMode: w.MenuMode,
-		})
+	for _, e := range s.entries {
+		kind, name := e.name()
+		current, ok := elected[kind][name]
+		if !ok || precedes(e.key, current.key) {
+			elected[kind][name] = e
+		}
 	}
-	s.catalog = catalog
-}
+	s.elected = elected
 
-func (s *store) watchRecursive(path string) error {
-	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+	lookup := func(kind string, name string) *wrap.Document {
+		if e, ok := elected[kind][name]; ok {
+			return e.doc
+		}
+		return nil
+	}
+	changed := changedKind + "/" + changedName
+	for name, e := range elected["wrap"] {
+		if e.doc.Composite == nil {
+			e.wrap, e.deps = e.doc.Wrap, nil
+			continue
+		}
+		var err error
+		e.wrap, e.deps, err = e.doc.Resolve(lookup)
 		if err != nil {
-			return err
+			s.logger.Warn("Unable to resolve wrap", "name", name, "error", err, "source", e.origin.Source, "location", e.origin.Location)
+			continue
 		}
-		if d.IsDir() {
-			return s.watcher.Add(p)
+		if slices.Contains(e.deps, changed) {
+			s.logger.Info("Reloaded wrap, as a dependency changed", "name", name, "dependency", changed)
 		}
-		return nil
-	})
+	}
+	s.rebuildCatalog()
 }
 
-func (s *store) watchLoop() {
-	defer func() { _ = s.watcher.Close() }()
-
-	for {
-		select {
-		case event, ok := <-s.watcher.Events:
-			if !ok {
-				return
-			}
+// precedes returns true if an entry with key k1 must win over one with key k2
+func precedes(k1 entryKey, k2 entryKey) bool {
+	if k1.source != k2.source {
+		return k1.source > k2.source
+	}
+	return k1.location < k2.location
+}
 
-			// Handle new directories (Watcher doesn't recursively watch new dirs automatically)
-			// But note: fsnotify events order for mkdir might vary.
-			// Ideally we check if it is a directory on CREATE.
-			if event.Op&fsnotify.Create == fsnotify.Create {
-				stat, err := os.Stat(event.Name)
-				if err == nil && stat.IsDir() {
-					_ = s.watcher.Add(event.Name)
-				}
-			}
+func (s *store) rebuildCatalog() {
+	s.catalog = s.buildCatalog(nil, nil)
+	s.reportUnknownWraps()
+}
 
-			if strings.HasSuffix(event.Name, ".yaml") {
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-					s.updateFile(event.Name)
-				} else if event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename {
-					s.removeFile(event.Name)
-				}
-			}
+// buildCatalog build the catalog from the elected wraps and menus, localized for the locales (Default texts if empty).
+// If identity is not nil, only the wraps it may use are listed.
+func (s *store) buildCatalog(locales []string, identity *auth.Identity) *Catalog {
+	catalog := &Catalog{
+		Wraps: make([]CatalogItem, 0, len(s.elected["wrap"])),
+	}
 
-		case err, ok := <-s.watcher.Errors:
-			if !ok {
-				return
+	for _, e := range s.elected["wrap"] {
+		if e.wrap == nil {
+			continue
+		}
+		w := e.wrap.Localize(locales)
+		operations := w.Operations
+		if identity != nil {
+			if !w.Usable(identity) {
+				continue
 			}
-			s.logger.Error("watcher error", "error", err)
+			operations = w.UserOperations(identity)
 		}
+		catalog.Wraps = append(catalog.Wraps, CatalogItem{
+			Name:        w.Name,
+			Label:       w.Label,
+			Description: w.Description,
+			Version:     w.Version,
+			Icon:        w.Icon,
+			Category:    w.Category,
+			Order:       w.Order,
+			MenuMode:    w.MenuMode,
+			Operations:  operations,
+			Origin:      e.origin,
+		})
+	}
+	// Wraps map iteration order is random. Sort to provide a stable menu
+	sort.Slice(catalog.Wraps, func(i, j int) bool {
+		return lessCatalogItem(&catalog.Wraps[i], &catalog.Wraps[j])
+	})
+	catalog.Menu = s.buildMenu(catalog.Wraps, locales)
+	return catalog
+}
+
+// lessCatalogItem order by explicit order, then by label. Name is used as last resort, to be deterministic
+func lessCatalogItem(i1 *CatalogItem, i2 *CatalogItem) bool {
+	if i1.Order != i2.Order {
+		return i1.Order < i2.Order
+	}
+	if i1.Label != i2.Label {
+		return i1.Label < i2.Label
 	}
+	return i1.Name < i2.Name
 }
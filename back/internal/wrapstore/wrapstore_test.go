@@ -1,10 +1,15 @@
 package wrapstore
 
 import (
+	"bytes"
+	"fmt"
+	"krapper/internal/auth"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -33,7 +38,11 @@ source:
 	}
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
-	ws, err := New(tmpDir, logger)
+	source, err := NewFolderSource(tmpDir, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := New(logger, source)
 	if err != nil {
 		t.Fatalf("New() failed: %v", err)
 	}
@@ -154,3 +163,328 @@ source:
 		t.Errorf("Expected 2 wraps (1 + 1 in subdir), got %d", len(catalog.Wraps))
 	}
 }
+
+func testWrap(name string, version string) string {
+	return fmt.Sprintf(`
+apiVersion: krapper.kubotal.io/v1alpha1
+kind: Wrap
+name: %s
+version: %s
+menuMode: grid
+source:
+  apiVersion: v1
+  kind: Pod
+`, name, version)
+}
+
+func TestWrapStorePrecedence(t *testing.T) {
+	folder1 := t.TempDir()
+	folder2 := t.TempDir()
+	embedded := fstest.MapFS{
+		"wraps/shared.yaml":   {Data: []byte(testWrap("shared", "embedded"))},
+		"wraps/embedded.yaml": {Data: []byte(testWrap("embedded", "embedded"))},
+	}
+	if err := os.WriteFile(filepath.Join(folder1, "shared.yaml"), []byte(testWrap("shared", "folder1")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder2, "shared.yaml"), []byte(testWrap("shared", "folder2")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	source1, err := NewFolderSource(folder1, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source2, err := NewFolderSource(folder2, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := New(logger, NewEmbeddedSource(embedded, logger), source1, source2)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	catalog := ws.GetCatalog()
+	if len(catalog.Wraps) != 2 {
+		t.Fatalf("Expected 2 wraps, got %d", len(catalog.Wraps))
+	}
+	if w := ws.GetWrap("shared"); w == nil || w.Version != "folder2" {
+		t.Errorf("Expected shared wrap from folder2, got %v", w)
+	}
+	for _, item := range catalog.Wraps {
+		expected := Origin{Source: "embedded", Location: "wraps/embedded.yaml"}
+		if item.Name == "shared" {
+			expected = Origin{Source: "folder", Location: filepath.Join(folder2, "shared.yaml")}
+		}
+		if item.Origin != expected {
+			t.Errorf("Wrap %s: expected origin %v, got %v", item.Name, expected, item.Origin)
+		}
+	}
+
+	// Removing the winner must reveal the shadowed definition
+	if err := os.Remove(filepath.Join(folder2, "shared.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if w := ws.GetWrap("shared"); w == nil || w.Version != "folder1" {
+		t.Errorf("Expected shared wrap from folder1, got %v", w)
+	}
+}
+
+func TestCatalogOrder(t *testing.T) {
+	embedded := fstest.MapFS{
+		"a.yaml": {Data: []byte(testWrap("zulu", "v1") + "order: 1\n")},
+		"b.yaml": {Data: []byte(testWrap("bravo", "v1") + "label: Charlie\n")},
+		"c.yaml": {Data: []byte(testWrap("alpha", "v1") + "label: Charlie\n")},
+		"d.yaml": {Data: []byte(testWrap("delta", "v1") + "label: Bravo\n")},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ws, err := New(logger, NewEmbeddedSource(embedded, logger))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	expected := []string{"delta", "alpha", "bravo", "zulu"}
+	catalog := ws.GetCatalog()
+	if len(catalog.Wraps) != len(expected) {
+		t.Fatalf("Expected %d wraps, got %d", len(expected), len(catalog.Wraps))
+	}
+	for idx, name := range expected {
+		if catalog.Wraps[idx].Name != name {
+			t.Errorf("Catalog[%d]: expected %s, got %s", idx, name, catalog.Wraps[idx].Name)
+		}
+	}
+}
+
+func TestCatalogMenu(t *testing.T) {
+	menu := `
+apiVersion: krapper.kubotal.io/v1alpha1
+kind: Menu
+name: main
+items:
+  - label: Kubauth
+    items:
+      - wrap: users
+      - wrap: groups
+        order: -1
+      - wrap: unknown
+  - label: Empty
+    items:
+      - wrap: unknown
+`
+	embedded := fstest.MapFS{
+		"menu.yaml":     {Data: []byte(menu)},
+		"users.yaml":    {Data: []byte(testWrap("users", "v1"))},
+		"groups.yaml":   {Data: []byte(testWrap("groups", "v1"))},
+		"releases.yaml": {Data: []byte(testWrap("releases", "v1") + "category: KuboCD\n")},
+		"pods.yaml":     {Data: []byte(testWrap("pods", "v1"))},
+	}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	ws, err := New(logger, NewEmbeddedSource(embedded, logger))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	// Unknown wraps are reported on store updates, not on each catalog request
+	reported := strings.Count(logs.String(), "unknown wrap")
+	if reported == 0 {
+		t.Errorf("Expected unknown wrap to be reported")
+	}
+	ws.GetUserCatalog(nil, &auth.Identity{Login: "jdoe"})
+	ws.GetLocalizedCatalog([]string{"fr"})
+	if got := strings.Count(logs.String(), "unknown wrap"); got != reported {
+		t.Errorf("Unknown wrap reported on catalog requests: %s", logs.String())
+	}
+	catalog := ws.GetCatalog()
+	if len(catalog.Wraps) != 4 {
+		t.Errorf("Expected 4 wraps in flat form, got %d", len(catalog.Wraps))
+	}
+	var dump func(nodes []*MenuNode) string
+	dump = func(nodes []*MenuNode) string {
+		result := ""
+		for _, node := range nodes {
+			if node.Wrap != "" {
+				result += node.Wrap + " "
+			} else {
+				result += node.Label + "[ " + dump(node.Children) + "] "
+			}
+		}
+		return result
+	}
+	expected := "Kubauth[ groups users ] pods KuboCD[ releases ] "
+	if got := dump(catalog.Menu); got != expected {
+		t.Errorf("Expected menu '%s', got '%s'", expected, got)
+	}
+}
+
+func TestCompositeWraps(t *testing.T) {
+	folder := t.TempDir()
+	fragment := func(label string) string {
+		return `
+apiVersion: krapper.kubotal.io/v1alpha1
+kind: Fragment
+name: common
+fields:
+  - name: comment
+    label: ` + label + `
+`
+	}
+	base := testWrap("base", "v1") + `
+schema:
+  fields:
+    - name: name
+    - include: common
+`
+	child := `
+apiVersion: krapper.kubotal.io/v1alpha1
+kind: Wrap
+name: child
+version: v2
+extends: base
+schema:
+  fields:
+    - name: name
+      label: Login
+    - name: extra
+`
+	for file, content := range map[string]string{"common.yaml": fragment("Comment"), "base.yaml": base, "child.yaml": child} {
+		if err := os.WriteFile(filepath.Join(folder, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	source, err := NewFolderSource(folder, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := New(logger, source)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	labels := func(name string) string {
+		w := ws.GetWrap(name)
+		if w == nil {
+			return "<nil>"
+		}
+		result := w.Version
+		for _, field := range w.Schema.Fields {
+			result += " " + field.Name + ":" + field.Label
+		}
+		return result
+	}
+	if got := labels("child"); got != "v2 name:Login comment:Comment extra:Extra" {
+		t.Errorf("Unexpected child wrap: %s", got)
+	}
+
+	// Editing the fragment must reload both wraps
+	if err := os.WriteFile(filepath.Join(folder, "common.yaml"), []byte(fragment("Remark")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if got := labels("base"); got != "v1 name:Name comment:Remark" {
+		t.Errorf("Unexpected base wrap: %s", got)
+	}
+	if got := labels("child"); got != "v2 name:Login comment:Remark extra:Extra" {
+		t.Errorf("Unexpected child wrap: %s", got)
+	}
+
+	// Without the fragment, dependant wraps are unavailable
+	if err := os.Remove(filepath.Join(folder, "common.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if ws.GetWrap("child") != nil || len(ws.GetCatalog().Wraps) != 0 {
+		t.Errorf("Expected no resolved wraps, got %v", ws.GetCatalog().Wraps)
+	}
+}
+
+func TestLocalizedCatalog(t *testing.T) {
+	menu := `
+apiVersion: krapper.kubotal.io/v1alpha1
+kind: Menu
+name: main
+items:
+  - label: {en: Accounts, fr: Comptes}
+    items:
+      - wrap: users
+`
+	embedded := fstest.MapFS{
+		"menu.yaml":   {Data: []byte(menu)},
+		"users.yaml":  {Data: []byte(testWrap("users", "v1") + "label: {en: Users, fr: Utilisateurs}\n")},
+		"groups.yaml": {Data: []byte(testWrap("groups", "v1") + "label: {en: Groups, fr: Groupes}\n")},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ws, err := New(logger, NewEmbeddedSource(embedded, logger))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	labels := func(catalog *Catalog) string {
+		result := ""
+		for _, item := range catalog.Wraps {
+			result += item.Label + " "
+		}
+		for _, node := range catalog.Menu {
+			result += "| " + node.Label
+		}
+		return result
+	}
+	if got := labels(ws.GetLocalizedCatalog(nil)); got != "Groups Users | Accounts| Groups" {
+		t.Errorf("Unexpected default catalog: '%s'", got)
+	}
+	if got := labels(ws.GetLocalizedCatalog([]string{"fr-FR"})); got != "Groupes Utilisateurs | Comptes| Groupes" {
+		t.Errorf("Unexpected fr catalog: '%s'", got)
+	}
+}
+
+func TestUserCatalog(t *testing.T) {
+	menu := `
+apiVersion: krapper.kubotal.io/v1alpha1
+kind: Menu
+name: main
+items:
+  - label: Accounts
+    items:
+      - wrap: users
+      - wrap: groups
+`
+	restricted := `
+operations:
+  view: true
+  update: true
+access:
+  view:
+    groups: [admins]
+  update:
+    groups: [admins]
+`
+	embedded := fstest.MapFS{
+		"menu.yaml":   {Data: []byte(menu)},
+		"users.yaml":  {Data: []byte(testWrap("users", "v1") + restricted)},
+		"groups.yaml": {Data: []byte(testWrap("groups", "v1") + "operations:\n  view: true\n  update: true\n")},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ws, err := New(logger, NewEmbeddedSource(embedded, logger))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	names := func(catalog *Catalog) string {
+		result := ""
+		for _, item := range catalog.Wraps {
+			result += fmt.Sprintf("%s(%v) ", item.Name, item.Operations.Update)
+		}
+		for _, node := range catalog.Menu[0].Children {
+			result += "| " + node.Wrap
+		}
+		return result
+	}
+	if got := names(ws.GetUserCatalog(nil, &auth.Identity{Login: "alice", Groups: []string{"admins"}})); got != "groups(true) users(true) | users| groups" {
+		t.Errorf("Unexpected admin catalog: '%s'", got)
+	}
+	if got := names(ws.GetUserCatalog(nil, &auth.Identity{Login: "jdoe", Groups: []string{"users"}})); got != "groups(true) | groups" {
+		t.Errorf("Unexpected user catalog: '%s'", got)
+	}
+	// Restricted wraps are still part of the unfiltered catalog
+	if got := len(ws.GetCatalog().Wraps); got != 2 {
+		t.Errorf("Expected 2 wraps in the full catalog, got %d", got)
+	}
+}